@@ -0,0 +1,485 @@
+package slogcloud
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+const (
+	// DefaultBatchSize is the number of buffered events that triggers a flush.
+	DefaultBatchSize = 100
+	// DefaultFlushInterval is the maximum time an event is buffered before it is flushed.
+	DefaultFlushInterval = 5 * time.Second
+	// DefaultQueueSize is the number of events that can wait to be batched.
+	DefaultQueueSize = 10000
+
+	// Hard limits CloudWatch enforces on a single PutLogEvents call.
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1048576
+
+	// CloudWatch counts every event as its UTF-8 message plus eventOverhead
+	// bytes, and rejects events larger than maxEventBytes.
+	eventOverhead   = 26
+	maxEventBytes   = 262144
+	maxEventMessage = maxEventBytes - eventOverhead
+
+	// CloudWatch rejects events older than maxEventAge or further than
+	// maxEventFuture ahead of the current time, and batches whose events
+	// span more than maxBatchSpan.
+	maxEventAge    = 14 * 24 * time.Hour
+	maxEventFuture = 2 * time.Hour
+	maxBatchSpan   = 24 * time.Hour
+)
+
+// ErrClientClosed is returned when logging to a CloudwatchClient that has been closed.
+var ErrClientClosed = errors.New("cloudwatch client is closed")
+
+// ErrQueueFull is returned when a log event is dropped by OverflowDropNewest.
+var ErrQueueFull = errors.New("cloudwatch log queue is full")
+
+// ErrMessageTooLarge is returned when a log is larger than the 256 KB
+// CloudWatch accepts for a single event and no WithMaxMessageBytes policy is
+// set to shrink it.
+var ErrMessageTooLarge = errors.New("log is too large for CloudWatch")
+
+// ErrEventsRejected is reported to OnError for log events CloudWatch rejected
+// from an otherwise accepted batch because they were too old, too new or
+// older than the log group's retention. Flush does not return it.
+var ErrEventsRejected = errors.New("CloudWatch rejected log events")
+
+// OverflowPolicy decides what happens to a log event emitted while the queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the caller wait until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the event being emitted.
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest queued event to make room.
+	OverflowDropOldest
+)
+
+// flushRequest asks the background goroutine to send everything queued so far.
+type flushRequest struct {
+	ctx  context.Context
+	done chan error
+}
+
+// Flush sends all pending log events to CloudWatch. It blocks until they have
+// been sent or the context is cancelled.
+func (cw *CloudwatchClient) Flush(ctx context.Context) error {
+	req := flushRequest{ctx: ctx, done: make(chan error, 1)}
+	select {
+	case cw.flushReqs <- req:
+	case <-cw.stopped:
+		// Close already flushed everything that was queued.
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes all pending log events and stops the background goroutines.
+// Logging to the client after Close returns ErrClientClosed.
+func (cw *CloudwatchClient) Close() error {
+	cw.closeOnce.Do(func() {
+		close(cw.closing)
+	})
+	<-cw.stopped
+	<-cw.errorsDone
+	return cw.closeErr
+}
+
+// enqueue hands event to the background goroutine, applying the overflow
+// policy when the queue is full.
+func (cw *CloudwatchClient) enqueue(ctx context.Context, event queuedEvent) error {
+	// A select picks at random among ready cases, so check for a closed
+	// client first rather than queue an event that is never sent
+	select {
+	case <-cw.closing:
+		return ErrClientClosed
+	default:
+	}
+
+	switch cw.opts.overflowPolicy {
+	case OverflowDropNewest:
+		select {
+		case cw.queue <- event:
+			return nil
+		case <-cw.closing:
+			return ErrClientClosed
+		default:
+			return ErrQueueFull
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case cw.queue <- event:
+				return nil
+			case <-cw.closing:
+				return ErrClientClosed
+			default:
+			}
+
+			// Make room by discarding the oldest queued event
+			select {
+			case <-cw.queue:
+				cw.opts.metrics.LogDropped(DropQueueFull)
+			default:
+			}
+		}
+	default:
+		// Queue without looking at ctx when there is room, so that a
+		// cancelled ctx only stops a caller that would have to wait
+		select {
+		case cw.queue <- event:
+			return nil
+		case <-cw.closing:
+			return ErrClientClosed
+		default:
+		}
+
+		select {
+		case cw.queue <- event:
+			return nil
+		case <-cw.closing:
+			return ErrClientClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// QueueLen returns the number of log events currently waiting to be batched.
+func (cw *CloudwatchClient) QueueLen() int {
+	return len(cw.queue)
+}
+
+// QueueHighWater returns the largest number of log events that have waited
+// in the queue at once since the client was created. Comparing it with the
+// queue size of WithQueueSize shows how close bursts come to blocking or
+// dropping logs.
+func (cw *CloudwatchClient) QueueHighWater() int {
+	return int(cw.highWater.Load())
+}
+
+// trackQueue records the queue length after an event was queued, raising the
+// high-water mark and warning through the Debugf function when the queue
+// fills past the WithQueueWarning threshold.
+func (cw *CloudwatchClient) trackQueue() {
+	n := int64(len(cw.queue))
+	for {
+		high := cw.highWater.Load()
+		if n <= high || cw.highWater.CompareAndSwap(high, n) {
+			break
+		}
+	}
+
+	if cw.opts.queueWarnPercent == 0 {
+		return
+	}
+	full := n*100 >= int64(cw.opts.queueWarnPercent)*int64(cap(cw.queue))
+	if full && cw.queueWarned.CompareAndSwap(false, true) {
+		cw.opts.debugf("Log queue is %d%% full with %d of %d events", n*100/int64(cap(cw.queue)), n, cap(cw.queue))
+	} else if !full && cw.queueWarned.Load() {
+		cw.queueWarned.Store(false)
+	}
+}
+
+// streamState is a log stream the client writes to along with the events
+// batched for it. It is only accessed by the background goroutine.
+type streamState struct {
+	group         string
+	name          string
+	events        []queuedEvent
+	sequenceToken *string
+
+	// lastUsed orders routed streams for eviction
+	lastUsed uint64
+
+	// lastTimestamp is the latest timestamp sent to the stream
+	lastTimestamp int64
+}
+
+// queuedEvent is a formatted event waiting to be batched for a stream. The
+// record it was formatted from is kept to report failures to it.
+type queuedEvent struct {
+	stream int
+	event  types.InputLogEvent
+	record slog.Record
+
+	// route is set instead of stream for events the router sent elsewhere
+	route *routeKey
+
+	// replayed is set for events read back from the disk buffer, which keep
+	// their original timestamp
+	replayed bool
+}
+
+// size returns the number of bytes CloudWatch counts for the event towards
+// the maxBatchBytes limit of a PutLogEvents call: its UTF-8 message plus
+// eventOverhead.
+func (qe queuedEvent) size() int {
+	return len(aws.ToString(qe.event.Message)) + eventOverhead
+}
+
+// pickStream returns the index of the stream the next event at level is sent
+// to: the error stream for errors, if there is one, or otherwise the next of
+// the client's streams round-robin.
+func (cw *CloudwatchClient) pickStream(level slog.Level) int {
+	if cw.opts.errorStream != "" && level >= slog.LevelError {
+		return cw.opts.streamCount
+	}
+	if cw.opts.streamCount == 1 {
+		return 0
+	}
+	return int((cw.nextStream.Add(1) - 1) % uint64(cw.opts.streamCount))
+}
+
+// run is the background goroutine that drains the queue and flushes the
+// batches once one is full or once the flush interval has elapsed.
+func (cw *CloudwatchClient) run() {
+	defer close(cw.stopped)
+
+	ticker := time.NewTicker(cw.opts.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case qe := <-cw.queue:
+			cw.rotateIfDue(qe)
+			stream := cw.streamFor(qe)
+			if stream == nil {
+				continue
+			}
+			stream.events = append(stream.events, qe)
+			if len(stream.events) >= cw.opts.batchSize {
+				if err := cw.flushStreams(context.TODO()); err != nil {
+					cw.opts.debugf("Failed to flush log events: %v", err)
+				}
+			}
+		case <-ticker.C:
+			if err := cw.flushStreams(context.TODO()); err != nil {
+				cw.opts.debugf("Failed to flush log events: %v", err)
+			}
+			cw.replayDisk()
+		case req := <-cw.flushReqs:
+			cw.drain()
+			req.done <- cw.flushStreams(req.ctx)
+		case req := <-cw.switchReqs:
+			req.done <- cw.switchStreams(req.ctx, req.streams)
+		case rot := <-cw.rotated:
+			cw.finishRotation(rot)
+		case <-cw.closing:
+			cw.drain()
+			cw.closeErr = cw.flushStreams(context.TODO())
+			if cw.disk != nil {
+				cw.closeErr = errors.Join(cw.closeErr, cw.disk.close())
+			}
+			return
+		}
+	}
+}
+
+// drain moves every event currently waiting in the queue onto its stream's batch.
+func (cw *CloudwatchClient) drain() {
+	for {
+		select {
+		case qe := <-cw.queue:
+			cw.rotateIfDue(qe)
+			if stream := cw.streamFor(qe); stream != nil {
+				stream.events = append(stream.events, qe)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// flushStreams sends the batched events of every stream, sending to multiple
+// streams in parallel.
+func (cw *CloudwatchClient) flushStreams(ctx context.Context) error {
+	if len(cw.streams) == 1 && len(cw.routes) == 0 {
+		return cw.sendBatch(ctx, cw.streams[0])
+	}
+
+	streams := cw.streams
+	for _, stream := range cw.routes {
+		streams = append(streams[:len(streams):len(streams)], stream)
+	}
+
+	errs := make([]error, len(streams))
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		if len(stream.events) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = cw.sendBatch(ctx, stream)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// sendBatch sends the events batched for stream to CloudWatch, splitting them
+// into as many PutLogEvents calls as needed to stay within the per-call limits.
+func (cw *CloudwatchClient) sendBatch(ctx context.Context, stream *streamState) error {
+	events := stream.events
+	stream.events = nil
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Events logged concurrently can reach the queue a few milliseconds out of
+	// order. One that arrives after a later event of the stream was already
+	// sent is stamped with that event's time, so the stream stays chronological.
+	for i, qe := range events {
+		if !qe.replayed && aws.ToInt64(qe.event.Timestamp) < stream.lastTimestamp {
+			events[i].event.Timestamp = aws.Int64(stream.lastTimestamp)
+		}
+	}
+
+	// CloudWatch rejects batches whose events are not in chronological order.
+	// A stable sort keeps events logged in the same millisecond in emit order.
+	slices.SortStableFunc(events, func(a, b queuedEvent) int {
+		return cmp.Compare(aws.ToInt64(a.event.Timestamp), aws.ToInt64(b.event.Timestamp))
+	})
+	stream.lastTimestamp = max(stream.lastTimestamp, aws.ToInt64(events[len(events)-1].event.Timestamp))
+
+	var errs []error
+	for len(events) > 0 {
+		n, size := 0, 0
+		first := aws.ToInt64(events[0].event.Timestamp)
+		for n < len(events) && n < maxBatchEvents {
+			eventSize := events[n].size()
+			if n > 0 && size+eventSize > maxBatchBytes {
+				break
+			}
+			if aws.ToInt64(events[n].event.Timestamp)-first >= maxBatchSpan.Milliseconds() {
+				break
+			}
+			size += eventSize
+			n++
+		}
+
+		if !cw.allowSend() {
+			err := fmt.Errorf("skipped sending %d log events: %w", n, ErrCircuitOpen)
+			errs = append(errs, err)
+			for _, qe := range events[:n] {
+				cw.opts.metrics.LogDropped(DropCircuitOpen)
+				cw.reportError(err, qe.record)
+			}
+			cw.writeFallback(events[:n])
+			cw.spool(stream, events[:n], err)
+			events = events[n:]
+			continue
+		}
+
+		logEvents := make([]types.InputLogEvent, n)
+		for i, qe := range events[:n] {
+			logEvents[i] = qe.event
+		}
+
+		output, err := cw.putLogEvents(ctx, stream, &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String(stream.group),
+			LogStreamName: aws.String(stream.name),
+			LogEvents:     logEvents,
+		})
+		cw.recordSend(err)
+		cw.sendFailed.Store(err != nil)
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			err = fmt.Errorf("failed to send %d log events: log group %s or stream %s does not exist: %w", n, stream.group, stream.name, err)
+		} else if err != nil {
+			err = fmt.Errorf("failed to send %d log events to CloudWatch: %w", n, err)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			cw.opts.metrics.LogsFailed(n)
+			cw.writeFallback(events[:n])
+			cw.spool(stream, events[:n], err)
+			for _, qe := range events[:n] {
+				cw.reportError(err, qe.record)
+			}
+		} else if !cw.handleRejected(output.RejectedLogEventsInfo, events[:n]) {
+			cw.opts.metrics.BatchSent(n, size)
+			cw.opts.debugf("Sent %d log events to stream %s", n, stream.name)
+		}
+		events = events[n:]
+	}
+
+	return errors.Join(errs...)
+}
+
+// handleRejected handles the events of a sent batch that CloudWatch rejected
+// as described by info while accepting the rest. The rejected events are
+// counted as failed, written to the fallback writer and reported to OnError
+// with an error wrapping ErrEventsRejected; the accepted ones count as sent.
+// Since the batch itself was accepted, nothing is returned to Flush. It
+// reports whether any event was rejected.
+func (cw *CloudwatchClient) handleRejected(info *types.RejectedLogEventsInfo, batch []queuedEvent) bool {
+	if info == nil {
+		return false
+	}
+
+	// Events before the end indexes are too old or expired, those from the
+	// start index on too new
+	n := len(batch)
+	tooOld := clampIndex(info.TooOldLogEventEndIndex, 0, n)
+	expired := clampIndex(info.ExpiredLogEventEndIndex, 0, n)
+	tooNew := clampIndex(info.TooNewLogEventStartIndex, n, n)
+	first := max(tooOld, expired)
+	last := max(tooNew, first)
+	if first == 0 && last == n {
+		return false
+	}
+
+	rejected := slices.Concat(batch[:first], batch[last:])
+	err := fmt.Errorf("%w: %d of %d events (%d too old, %d expired, %d too new)",
+		ErrEventsRejected, len(rejected), n, tooOld, expired, n-tooNew)
+	cw.opts.debugf("%v", err)
+	cw.opts.metrics.LogsFailed(len(rejected))
+	cw.writeFallback(rejected)
+	for _, qe := range rejected {
+		cw.reportError(err, qe.record)
+	}
+
+	if accepted := batch[first:last]; len(accepted) > 0 {
+		size := 0
+		for _, qe := range accepted {
+			size += qe.size()
+		}
+		cw.opts.metrics.BatchSent(len(accepted), size)
+	}
+	return true
+}
+
+// clampIndex returns the index i points to, limited to [0, n], or def if i
+// is nil.
+func clampIndex(i *int32, def, n int) int {
+	if i == nil {
+		return def
+	}
+	return min(max(int(*i), 0), n)
+}
@@ -0,0 +1,121 @@
+package slogcloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestPartialRejection(t *testing.T) {
+	var fallback bytes.Buffer
+	var debug []string
+	reported := make(chan error, 5)
+	cw, fake := newTestClient(t,
+		WithFlushInterval(time.Hour),
+		WithFallbackWriter(&fallback),
+		WithDebugf(func(format string, args ...any) { debug = append(debug, fmt.Sprintf(format, args...)) }),
+		WithOnError(func(err error, _ slog.Record) { reported <- err }),
+	)
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		return &cloudwatchlogs.PutLogEventsOutput{RejectedLogEventsInfo: &types.RejectedLogEventsInfo{
+			TooOldLogEventEndIndex:   aws.Int32(1),
+			TooNewLogEventStartIndex: aws.Int32(4),
+		}}, nil
+	})
+
+	now := time.Now()
+	for i := range 5 {
+		if err := cw.EmitLog(slog.NewRecord(now.Add(time.Duration(i)*time.Millisecond), slog.LevelInfo, fmt.Sprintf("log %d", i), 0)); err != nil {
+			t.Fatalf("EmitLog: %v", err)
+		}
+	}
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush = %v, want nil for an accepted batch", err)
+	}
+
+	// The rejected logs are reported to the callback in the background
+	for range 2 {
+		select {
+		case err := <-reported:
+			if !errors.Is(err, ErrEventsRejected) || !strings.Contains(err.Error(), "2 of 5 events (1 too old, 0 expired, 1 too new)") {
+				t.Errorf("OnError got %v, want it to describe the rejected events", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnError was not called for the rejected logs")
+		}
+	}
+
+	if calls := fake.putCalls(); len(calls) != 1 {
+		t.Errorf("got %d PutLogEvents calls, want 1 without retrying the accepted events", len(calls))
+	}
+	lines := strings.Split(strings.TrimSpace(fallback.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"log 0"`) || !strings.Contains(lines[1], `"log 4"`) {
+		t.Errorf("fallback writer got %q, want the rejected logs 0 and 4", lines)
+	}
+	if !slices.ContainsFunc(debug, func(s string) bool { return strings.Contains(s, ErrEventsRejected.Error()) }) {
+		t.Errorf("debug output %q does not warn about the rejected events", debug)
+	}
+}
+
+// rawFormatter sends the record's message as it is, so tests control the
+// exact size of each event.
+type rawFormatter struct{}
+
+func (rawFormatter) Format(r slog.Record, _ map[string]any) ([]byte, error) {
+	return []byte(r.Message), nil
+}
+
+func TestBatchByteLimit(t *testing.T) {
+	// Three events of the largest size and two small ones, sized so the
+	// batch lands exactly at the limit or one byte over it
+	full := maxEventMessage
+	tests := []struct {
+		name      string
+		sizes     []int
+		wantCalls int
+	}{
+		{"at the limit", []int{full, full, full, maxBatchBytes - 3*maxEventBytes - 2*eventOverhead - 1, 1}, 1},
+		{"one byte over", []int{full, full, full, maxBatchBytes - 3*maxEventBytes - 2*eventOverhead, 1}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cw, fake := newTestClient(t, WithFlushInterval(time.Hour), WithFormatter(rawFormatter{}))
+			total := 0
+			now := time.Now()
+			for i, size := range tt.sizes {
+				total += size + eventOverhead
+				r := slog.NewRecord(now.Add(time.Duration(i)*time.Millisecond), slog.LevelInfo, strings.Repeat("x", size), 0)
+				if err := cw.EmitLog(r); err != nil {
+					t.Fatalf("EmitLog: %v", err)
+				}
+			}
+			if err := cw.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			calls := fake.putCalls()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("batch of %d bytes sent in %d calls, want %d", total, len(calls), tt.wantCalls)
+			}
+			for _, call := range calls {
+				size := 0
+				for _, e := range call.LogEvents {
+					size += len(aws.ToString(e.Message)) + eventOverhead
+				}
+				if size > maxBatchBytes {
+					t.Errorf("sent a batch of %d bytes, over the limit of %d", size, maxBatchBytes)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is reported for logs that were not sent because the circuit
+// breaker enabled by WithCircuitBreaker is open.
+var ErrCircuitOpen = errors.New("cloudwatch circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker enabled by WithCircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed sends batches to CloudWatch as usual.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen skips CloudWatch until the cooldown has passed. Batches go
+	// to the fallback writer, if there is one, and are otherwise dropped.
+	CircuitOpen
+	// CircuitHalfOpen sends a single batch to probe whether CloudWatch has
+	// recovered, closing the circuit if it succeeds and opening it again if
+	// it fails.
+	CircuitHalfOpen
+)
+
+// String returns the name of the state, such as "open".
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breaker stops sending to CloudWatch after consecutive failures. Streams are
+// flushed in parallel, so it is guarded by a mutex.
+type breaker struct {
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// CircuitState returns the state of the circuit breaker. It is always
+// CircuitClosed without WithCircuitBreaker.
+func (cw *CloudwatchClient) CircuitState() CircuitState {
+	cw.breaker.mu.Lock()
+	defer cw.breaker.mu.Unlock()
+	return cw.breaker.state
+}
+
+// allowSend reports whether a batch may be sent to CloudWatch.
+func (cw *CloudwatchClient) allowSend() bool {
+	if cw.opts.breakerThreshold <= 0 {
+		return true
+	}
+
+	b := &cw.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < cw.opts.breakerCooldown {
+			return false
+		}
+		cw.setCircuitState(CircuitHalfOpen)
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		// Only one probe at a time
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// recordSend updates the circuit breaker with the result of sending a batch.
+func (cw *CloudwatchClient) recordSend(err error) {
+	if cw.opts.breakerThreshold <= 0 {
+		return
+	}
+
+	b := &cw.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	// Giving up because the caller's context ended says nothing about
+	// CloudWatch, so leave the state as it was and let the next batch probe
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	if err == nil {
+		b.failures = 0
+		if b.state != CircuitClosed {
+			cw.setCircuitState(CircuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= cw.opts.breakerThreshold {
+		b.openedAt = time.Now()
+		cw.setCircuitState(CircuitOpen)
+	}
+}
+
+// setCircuitState moves the breaker to state. The breaker must be locked.
+func (cw *CloudwatchClient) setCircuitState(state CircuitState) {
+	if cw.breaker.state == state {
+		return
+	}
+	cw.opts.debugf("Circuit breaker is %s", state)
+	cw.breaker.state = state
+	cw.opts.metrics.CircuitStateChanged(state)
+}
@@ -0,0 +1,74 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestRecordSend(t *testing.T) {
+	errSend := errors.New("throttled")
+	tests := []struct {
+		name  string
+		state CircuitState
+		err   error
+		want  CircuitState
+	}{
+		{"success closes half-open", CircuitHalfOpen, nil, CircuitClosed},
+		{"failure reopens half-open", CircuitHalfOpen, errSend, CircuitOpen},
+		{"failure at threshold opens", CircuitClosed, errSend, CircuitOpen},
+		{"canceled keeps half-open", CircuitHalfOpen, context.Canceled, CircuitHalfOpen},
+		{"deadline keeps half-open", CircuitHalfOpen, fmt.Errorf("put: %w", context.DeadlineExceeded), CircuitHalfOpen},
+		{"canceled keeps closed", CircuitClosed, context.Canceled, CircuitClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultOptions()
+			WithCircuitBreaker(2, time.Minute)(&opts)
+			cw := &CloudwatchClient{opts: opts}
+			cw.breaker.state = tt.state
+			cw.breaker.failures = 1
+			cw.breaker.probing = tt.state == CircuitHalfOpen
+
+			cw.recordSend(tt.err)
+
+			if got := cw.CircuitState(); got != tt.want {
+				t.Errorf("state = %s, want %s", got, tt.want)
+			}
+			if cw.breaker.probing {
+				t.Error("probe was not cleared")
+			}
+		})
+	}
+}
+
+func TestBreakerIgnoresCancelledSend(t *testing.T) {
+	cw, fake := newTestClient(t, WithCircuitBreaker(1, time.Minute), WithMaxRetries(5), WithRetryDelay(time.Hour, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		// Give up while the client waits to retry
+		cancel()
+		return nil, &types.ServiceUnavailableException{Message: aws.String("unavailable")}
+	})
+
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0)); err != nil {
+		t.Fatalf("EmitLog: %v", err)
+	}
+	_ = cw.Flush(ctx)
+	// Wait for the cancelled send to finish
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if got := cw.CircuitState(); got != CircuitClosed {
+		t.Errorf("state after a cancelled send = %s, want %s", got, CircuitClosed)
+	}
+}
@@ -0,0 +1,18 @@
+package slogcloud
+
+import "time"
+
+// Clock tells the client the current time. Inject a fixed clock with
+// WithClock to make timestamps and generated stream names deterministic in
+// tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock that reads the system time. It is the default.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
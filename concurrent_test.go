@@ -0,0 +1,169 @@
+package slogcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestConcurrentEmitters logs from many goroutines while others flush, switch
+// streams and read the client's state. Run it with -race.
+func TestConcurrentEmitters(t *testing.T) {
+	const (
+		emitters = 32
+		perEmit  = 200
+	)
+	router := func(r slog.Record) (group, stream string) {
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "tenant" {
+				group = fmt.Sprintf("tenant-%d", a.Value.Int64())
+				return false
+			}
+			return true
+		})
+		return group, ""
+	}
+	cw, fake := newTestClient(t,
+		WithBatchSize(16),
+		WithQueueSize(64),
+		WithFlushInterval(time.Millisecond),
+		WithStreamCount(3),
+		WithErrorStream("errors"),
+		WithRouter(router),
+		WithMaxRoutes(2),
+		WithSequenceNumbers(true),
+		WithCircuitBreaker(5, time.Second),
+	)
+	logger := slog.New(NewCloudWatchLogHandler(cw)).With("service", "checkout")
+
+	var emitting sync.WaitGroup
+	for g := range emitters {
+		emitting.Add(1)
+		go func() {
+			defer emitting.Done()
+			l := logger.WithGroup("req").With("goroutine", g)
+			for i := range perEmit {
+				switch i % 4 {
+				case 0:
+					l.Info("tick", "i", i)
+				case 1:
+					l.Error("failed", "i", i)
+				default:
+					l.Info("routed", "tenant", i%4, "i", i)
+				}
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			switch i % 3 {
+			case 0:
+				_ = cw.Flush(context.Background())
+			case 1:
+				_ = cw.SetLogStream(fmt.Sprintf("stream-%d", i))
+			default:
+				_ = cw.LogStreams()
+				_ = cw.QueueLen()
+				_ = cw.CircuitState()
+			}
+		}
+	}()
+
+	emitting.Wait()
+	close(stop)
+	background.Wait()
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	entries := fake.entries(t)
+	if len(entries) != emitters*perEmit {
+		t.Fatalf("sent %d logs, want %d", len(entries), emitters*perEmit)
+	}
+	seen := make(map[float64]bool, len(entries))
+	for _, entry := range entries {
+		seq, _ := entry[SeqKey].(float64)
+		if seq < 1 || seq > emitters*perEmit || seen[seq] {
+			t.Fatalf("log %v has a missing, out of range or repeated sequence number", entry)
+		}
+		seen[seq] = true
+	}
+}
+
+// TestConcurrentOrdering checks that every stream receives its events in
+// chronological order, and each goroutine's events in the order it logged
+// them, while other goroutines log and flush at the same time.
+func TestConcurrentOrdering(t *testing.T) {
+	const (
+		emitters = 16
+		perEmit  = 300
+	)
+	cw, fake := newTestClient(t, WithBatchSize(8), WithFlushInterval(time.Millisecond), WithStreamCount(2))
+	logger := slog.New(NewCloudWatchLogHandler(cw))
+
+	var wg sync.WaitGroup
+	for g := range emitters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perEmit {
+				logger.Info("tick", "g", g, "i", i)
+				if i%50 == 0 {
+					_ = cw.Flush(context.Background())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := cw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	type position struct {
+		stream string
+		g      int
+	}
+	lastTimestamp := make(map[string]int64)
+	lastIndex := make(map[position]int)
+	sent := 0
+	for _, call := range fake.putCalls() {
+		stream := aws.ToString(call.LogStreamName)
+		for _, e := range call.LogEvents {
+			sent++
+			ts := aws.ToInt64(e.Timestamp)
+			if ts < lastTimestamp[stream] {
+				t.Fatalf("stream %s went back in time from %d to %d", stream, lastTimestamp[stream], ts)
+			}
+			lastTimestamp[stream] = ts
+
+			var entry struct{ G, I int }
+			if err := json.Unmarshal([]byte(aws.ToString(e.Message)), &entry); err != nil {
+				t.Fatal(err)
+			}
+			pos := position{stream, entry.G}
+			if last, ok := lastIndex[pos]; ok && entry.I <= last {
+				t.Fatalf("stream %s got log %d of goroutine %d after log %d", stream, entry.I, entry.G, last)
+			}
+			lastIndex[pos] = entry.I
+		}
+	}
+	if sent != emitters*perEmit {
+		t.Errorf("sent %d logs, want %d", sent, emitters*perEmit)
+	}
+}
@@ -0,0 +1,317 @@
+package slogcloud
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ANSI escape codes used to colorize console output.
+const (
+	colorReset   = "\x1b[0m"
+	colorGray    = "\x1b[90m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+)
+
+// consoleTimeFormat is the default layout of the time ConsoleHandler prints.
+const consoleTimeFormat = "15:04:05.000"
+
+// ConsoleHandler is a slog.Handler for local development that prints each
+// record on one line with a timestamp, a colorized level, the message and
+// its attributes as key=value pairs:
+//
+//	15:04:05.000 INFO  user logged in user.id=42 path=/login
+type ConsoleHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Leveler
+	color bool
+
+	// timeFormat and utc control how the time of each record is printed
+	timeFormat string
+	utc        bool
+
+	// attrs are the pre-formatted attributes added by WithAttrs
+	attrs  string
+	prefix string
+
+	// levelWriters replace w for records at or above their level
+	levelWriters []levelWriter
+
+	// levelStyles override the label and color of levels
+	levelStyles map[slog.Level]LevelStyle
+}
+
+// LevelStyle is how ConsoleHandler prints a level: its label, such as "WRN",
+// and the ANSI escape sequence coloring it, such as "\x1b[33m" for yellow.
+// An empty field keeps the default.
+type LevelStyle struct {
+	Label string
+	Color string
+}
+
+// levelWriter is a writer for the records at or above level.
+type levelWriter struct {
+	level slog.Level
+	w     io.Writer
+}
+
+// writerFor returns the writer of the levelWriter with the highest level at or
+// below level, or w if there is none.
+func writerFor(w io.Writer, levelWriters []levelWriter, level slog.Level) io.Writer {
+	best := slog.Level(math.MinInt)
+	for _, lw := range levelWriters {
+		if level >= lw.level && lw.level >= best {
+			w, best = lw.w, lw.level
+		}
+	}
+	return w
+}
+
+// ConsoleOption configures a ConsoleHandler.
+type ConsoleOption func(*ConsoleHandler)
+
+// WithColor turns colored output on or off. By default output is colored
+// when writing to a terminal and the NO_COLOR environment variable is unset.
+func WithColor(color bool) ConsoleOption {
+	return func(h *ConsoleHandler) {
+		h.color = color
+	}
+}
+
+// WithConsoleLevel sets the minimum level of records printed. The default is
+// slog.LevelDebug.
+func WithConsoleLevel(level slog.Leveler) ConsoleOption {
+	return func(h *ConsoleHandler) {
+		h.level = level
+	}
+}
+
+// WithConsoleTimeFormat sets the layout, as used by time.Format, of the time
+// printed at the start of each line. The default is "15:04:05.000"; use
+// DefaultTimeFormat or time.RFC3339 to include the date.
+func WithConsoleTimeFormat(layout string) ConsoleOption {
+	return func(h *ConsoleHandler) {
+		h.timeFormat = layout
+	}
+}
+
+// WithConsoleUTC prints times in UTC instead of the local time zone.
+func WithConsoleUTC(utc bool) ConsoleOption {
+	return func(h *ConsoleHandler) {
+		h.utc = utc
+	}
+}
+
+// WithLevelStyles sets the label and color printed for levels, for example
+// to shorten WARN to WRN or match a terminal theme. Levels not in styles keep
+// their default style. Colors are only printed when colored output is on, so
+// NO_COLOR and WithColor(false) still apply.
+func WithLevelStyles(styles map[slog.Level]LevelStyle) ConsoleOption {
+	return func(h *ConsoleHandler) {
+		h.levelStyles = maps.Clone(styles)
+	}
+}
+
+// WithConsoleLevelWriter prints records at or above level to w instead of
+// the handler's writer, for example WithConsoleLevelWriter(slog.LevelWarn,
+// os.Stderr) to send warnings and errors to stderr. When given several times,
+// a record goes to the writer with the highest level it reaches.
+func WithConsoleLevelWriter(level slog.Level, w io.Writer) ConsoleOption {
+	return func(h *ConsoleHandler) {
+		h.levelWriters = append(h.levelWriters, levelWriter{level: level, w: w})
+	}
+}
+
+// NewConsoleHandler creates a ConsoleHandler writing to w.
+func NewConsoleHandler(w io.Writer, opts ...ConsoleOption) *ConsoleHandler {
+	h := &ConsoleHandler{
+		w:          w,
+		mu:         new(sync.Mutex),
+		level:      slog.LevelDebug,
+		color:      colorSupported(w),
+		timeFormat: consoleTimeFormat,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// formatTime formats t with layout, in UTC if utc is set.
+func formatTime(t time.Time, layout string, utc bool) string {
+	if utc {
+		t = t.UTC()
+	}
+	return t.Format(layout)
+}
+
+// colorSupported reports whether w is a terminal and NO_COLOR is unset.
+func colorSupported(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Enabled reports whether records at level are printed.
+func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle prints r as a single line.
+func (h *ConsoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+
+	if !r.Time.IsZero() {
+		h.paint(&buf, colorGray, formatTime(r.Time, h.timeFormat, h.utc))
+		buf.WriteByte(' ')
+	}
+	style := h.levelStyle(r.Level)
+	h.paint(&buf, style.Color, padLevel(style.Label))
+	buf.WriteByte(' ')
+	buf.WriteString(r.Message)
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&buf, h.prefix, a)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := writerFor(h.w, h.levelWriters, r.Level).Write(buf.Bytes())
+	return err
+}
+
+// WithAttrs returns a handler that prints attrs with every record.
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+	for _, a := range attrs {
+		h.appendAttr(&buf, h.prefix, a)
+	}
+	h2 := *h
+	h2.attrs = h.attrs + buf.String()
+	return &h2
+}
+
+// WithGroup returns a handler that prefixes the keys of later attributes
+// with name.
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// appendAttr writes a as " key=value", flattening groups into dotted keys.
+func (h *ConsoleHandler) appendAttr(buf *bytes.Buffer, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(buf, prefix, ga)
+		}
+		return
+	}
+
+	buf.WriteByte(' ')
+	h.paint(buf, colorCyan, prefix+a.Key+"=")
+	buf.WriteString(quoteIfNeeded(consoleValue(a.Value)))
+}
+
+// paint writes s to buf, wrapped in color when colors are enabled.
+func (h *ConsoleHandler) paint(buf *bytes.Buffer, color, s string) {
+	if !h.color {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(colorReset)
+}
+
+// consoleValue returns the text printed for v.
+func consoleValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+	}
+	return v.String()
+}
+
+// quoteIfNeeded quotes s if it is empty or contains spaces, quotes, '=' or
+// control characters, so each key=value pair stays unambiguous.
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '=' || r == 0x7f
+	}) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// levelStyle returns the label and color level is printed with.
+func (h *ConsoleHandler) levelStyle(level slog.Level) LevelStyle {
+	style := h.levelStyles[level]
+	if style.Label == "" {
+		style.Label = levelString(level)
+	}
+	if style.Color == "" {
+		style.Color = levelColor(level)
+	}
+	return style
+}
+
+// levelColor returns the color a level is printed in.
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= LevelFatal:
+		return colorMagenta
+	case level >= slog.LevelError:
+		return colorRed
+	case level >= slog.LevelWarn:
+		return colorYellow
+	case level >= slog.LevelInfo:
+		return colorGreen
+	default:
+		return colorGray
+	}
+}
+
+// padLevel pads a level name to five characters so messages line up.
+func padLevel(s string) string {
+	if len(s) < 5 {
+		return s + strings.Repeat(" ", 5-len(s))
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package slogcloud
+
+import (
+	"context"
+	"log/slog"
+)
+
+// CorrelationIDKey is the key the correlation ID is added under by
+// WithCorrelationID.
+const CorrelationIDKey = "correlation_id"
+
+// correlationIDKey is the context key of the correlation ID. Its type is
+// unexported so no other package can collide with it.
+type correlationIDKey struct{}
+
+// ContextWithCorrelationID returns a copy of ctx carrying id, typically set
+// once at the edge of a request, for example in HTTP middleware.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID in ctx, and whether it
+// holds one.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// WithCorrelationID adds the correlation ID set with ContextWithCorrelationID
+// to every log under "correlation_id", when the context passed to the logger
+// holds one.
+func WithCorrelationID() HandlerOption {
+	return WithContextExtractor(correlationAttrs)
+}
+
+// correlationAttrs returns the correlation ID in ctx as an attribute, or nil.
+func correlationAttrs(ctx context.Context) []slog.Attr {
+	id, ok := CorrelationIDFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []slog.Attr{slog.String(CorrelationIDKey, id)}
+}
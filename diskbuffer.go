@@ -0,0 +1,325 @@
+package slogcloud
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+const (
+	// diskSegmentPrefix and diskSegmentSuffix frame the names of the files of
+	// a disk buffer, which sort in the order they were written.
+	diskSegmentPrefix = "slogcloud-"
+	diskSegmentSuffix = ".jsonl"
+
+	// diskSegments is roughly how many files a full disk buffer is split
+	// into, so the oldest logs can be dropped a file at a time.
+	diskSegments = 8
+)
+
+// diskEvent is a log event written to a disk buffer, one JSON document per
+// line, with the log group and stream it was meant for.
+type diskEvent struct {
+	Group     string `json:"group"`
+	Stream    string `json:"stream"`
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+// diskSegment is a file of a disk buffer.
+type diskSegment struct {
+	path   string
+	size   int64
+	events int
+}
+
+// diskBuffer stores log events that could not be sent in files in a
+// directory until they can be replayed. Once the files exceed maxBytes the
+// oldest is deleted. It is safe for concurrent use, as streams are flushed in
+// parallel.
+type diskBuffer struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	mu       sync.Mutex
+	segments []diskSegment
+	size     int64
+	// current is the segment being appended to, the last of segments
+	current *os.File
+	// nextID names the next segment
+	nextID int64
+}
+
+// openDiskBuffer opens the disk buffer in dir, creating the directory if
+// needed. Files left by an earlier run are kept to be replayed.
+func openDiskBuffer(dir string, maxBytes int64) (*diskBuffer, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid disk buffer size of %d bytes", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create disk buffer: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read disk buffer: %w", err)
+	}
+
+	b := &diskBuffer{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		segmentBytes: max(maxBytes/diskSegments, 1),
+		nextID:       time.Now().UnixNano(),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, diskSegmentPrefix) || !strings.HasSuffix(name, diskSegmentSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read disk buffer: %w", err)
+		}
+		b.segments = append(b.segments, diskSegment{path: path, size: int64(len(data)), events: bytes.Count(data, []byte("\n"))})
+		b.size += int64(len(data))
+	}
+	// ReadDir returns the files sorted by name, which is the order they were written
+	return b, nil
+}
+
+// append writes events to the buffer, deleting the oldest files if it grows
+// past its maximum size. It returns the number of events deleted.
+func (b *diskBuffer) append(events []diskEvent) (dropped int, err error) {
+	var buf bytes.Buffer
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current == nil || b.segments[len(b.segments)-1].size >= b.segmentBytes {
+		if err := b.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := b.current.Write(buf.Bytes())
+	last := &b.segments[len(b.segments)-1]
+	last.size += int64(n)
+	last.events += len(events)
+	b.size += int64(n)
+	if err != nil {
+		return 0, fmt.Errorf("could not write to disk buffer: %w", err)
+	}
+
+	for b.size > b.maxBytes && len(b.segments) > 1 {
+		oldest := b.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return dropped, fmt.Errorf("could not trim disk buffer: %w", err)
+		}
+		b.segments = b.segments[1:]
+		b.size -= oldest.size
+		dropped += oldest.events
+	}
+	return dropped, nil
+}
+
+// rotate closes the current file and starts a new one. b.mu must be held.
+func (b *diskBuffer) rotate() error {
+	if b.current != nil {
+		b.current.Close()
+		b.current = nil
+	}
+
+	b.nextID++
+	path := filepath.Join(b.dir, fmt.Sprintf("%s%020d%s", diskSegmentPrefix, b.nextID, diskSegmentSuffix))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not create disk buffer file: %w", err)
+	}
+	b.current = f
+	b.segments = append(b.segments, diskSegment{path: path})
+	return nil
+}
+
+// oldest returns the path and events of the oldest file, closing it first if
+// it is still being appended to. It returns false if the buffer is empty.
+func (b *diskBuffer) oldest() (string, []diskEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.segments) == 0 {
+		return "", nil, false
+	}
+	if len(b.segments) == 1 && b.current != nil {
+		b.current.Close()
+		b.current = nil
+	}
+
+	path := b.segments[0].path
+	f, err := os.Open(path)
+	if err != nil {
+		return path, nil, true
+	}
+	defer f.Close()
+
+	var events []diskEvent
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		var e diskEvent
+		// Skip lines torn by a crash while writing
+		if json.Unmarshal(line, &e) == nil {
+			events = append(events, e)
+		}
+		if err != nil {
+			return path, events, true
+		}
+	}
+}
+
+// remove deletes the file at path once its events have been replayed.
+func (b *diskBuffer) remove(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := slices.IndexFunc(b.segments, func(s diskSegment) bool { return s.path == path })
+	if i < 0 {
+		return nil
+	}
+	if i == len(b.segments)-1 && b.current != nil {
+		b.current.Close()
+		b.current = nil
+	}
+	b.size -= b.segments[i].size
+	b.segments = slices.Delete(b.segments, i, i+1)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// close closes the file being appended to.
+func (b *diskBuffer) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current == nil {
+		return nil
+	}
+	err := b.current.Close()
+	b.current = nil
+	return err
+}
+
+// spool writes events that failed to be sent to stream to the disk buffer, if
+// there is one. Events CloudWatch rejected for good, such as for a missing
+// log group or an invalid parameter, are not kept as replaying them would
+// fail again.
+func (cw *CloudwatchClient) spool(stream *streamState, events []queuedEvent, err error) {
+	if cw.disk == nil {
+		return
+	}
+	var notFound *types.ResourceNotFoundException
+	var invalid *types.InvalidParameterException
+	if errors.As(err, &notFound) || errors.As(err, &invalid) {
+		return
+	}
+
+	spooled := make([]diskEvent, len(events))
+	for i, qe := range events {
+		spooled[i] = diskEvent{
+			Group:     stream.group,
+			Stream:    stream.name,
+			Timestamp: aws.ToInt64(qe.event.Timestamp),
+			Message:   aws.ToString(qe.event.Message),
+		}
+	}
+
+	dropped, err := cw.disk.append(spooled)
+	if err != nil {
+		cw.opts.debugf("Failed to write %d log events to the disk buffer: %v", len(events), err)
+		return
+	}
+	cw.opts.debugf("Wrote %d log events to the disk buffer", len(events))
+	for range dropped {
+		cw.opts.metrics.LogDropped(DropDiskBufferFull)
+	}
+}
+
+// replayDisk sends the events of the oldest file of the disk buffer once
+// sending to CloudWatch works, one file per flush interval so a long outage
+// does not hold up new logs. Events that fail again are written back to the
+// buffer by the failed flush. It is only called by the background goroutine.
+func (cw *CloudwatchClient) replayDisk() {
+	if cw.disk == nil || cw.sendFailed.Load() {
+		return
+	}
+	path, events, ok := cw.disk.oldest()
+	if !ok {
+		return
+	}
+
+	oldest := cw.opts.clock.Now().Add(-maxEventAge).UnixMilli()
+	replayed := 0
+	for _, e := range events {
+		if e.Timestamp < oldest {
+			cw.opts.metrics.LogsFailed(1)
+			continue
+		}
+		qe := queuedEvent{
+			event: types.InputLogEvent{
+				Message:   aws.String(e.Message),
+				Timestamp: aws.Int64(e.Timestamp),
+			},
+			record:   slog.NewRecord(time.UnixMilli(e.Timestamp), slog.LevelInfo, e.Message, 0),
+			replayed: true,
+		}
+		if i := cw.streamIndex(e.Group, e.Stream); i >= 0 {
+			qe.stream = i
+		} else {
+			qe.route = &routeKey{group: e.Group, stream: e.Stream}
+		}
+		if stream := cw.streamFor(qe); stream != nil {
+			stream.events = append(stream.events, qe)
+			replayed++
+		}
+	}
+
+	if err := cw.flushStreams(context.TODO()); err != nil {
+		cw.opts.debugf("Failed to replay log events from the disk buffer: %v", err)
+	} else {
+		cw.opts.debugf("Replayed %d log events from the disk buffer", replayed)
+	}
+	if err := cw.disk.remove(path); err != nil {
+		cw.opts.debugf("Failed to remove replayed disk buffer file %s: %v", path, err)
+	}
+}
+
+// streamIndex returns the index of the client's stream named name in group,
+// or -1 if it is not one of them.
+func (cw *CloudwatchClient) streamIndex(group, name string) int {
+	if group != cw.logGroup {
+		return -1
+	}
+	return slices.IndexFunc(cw.streams, func(s *streamState) bool { return s.name == name })
+}
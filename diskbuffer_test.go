@@ -0,0 +1,162 @@
+package slogcloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// diskFiles returns the names of the files in a disk buffer directory.
+func diskFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDiskBufferSpoolAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	cw, fake := newTestClient(t, WithDiskBuffer(dir, 1<<20), WithMaxRetries(0), WithFlushInterval(10*time.Millisecond))
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		return nil, &types.ServiceUnavailableException{Message: aws.String("unavailable")}
+	})
+
+	now := time.Now()
+	for i := range 3 {
+		if err := cw.EmitLog(slog.NewRecord(now.Add(time.Duration(i)*time.Millisecond), slog.LevelInfo, fmt.Sprintf("outage %d", i), 0)); err != nil {
+			t.Fatalf("EmitLog: %v", err)
+		}
+	}
+	if err := cw.Flush(context.Background()); err == nil {
+		t.Fatal("Flush succeeded while CloudWatch was failing")
+	}
+	files := diskFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("disk buffer holds %q, want one file", files)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, files[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Fatalf("disk buffer holds %d events, want 3", n)
+	}
+
+	// Once a send succeeds the spooled events are replayed and the file removed
+	fake.setPut(nil)
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "recovered", 0)); err != nil {
+		t.Fatalf("EmitLog: %v", err)
+	}
+	waitFor(t, "the replay", func() bool { return len(diskFiles(t, dir)) == 0 })
+
+	var sent []string
+	for _, entry := range fake.entries(t)[3:] {
+		sent = append(sent, entry["message"].(string))
+	}
+	if want := []string{"recovered", "outage 0", "outage 1", "outage 2"}; !slices.Equal(sent, want) {
+		t.Errorf("sent %q after recovering, want %q", sent, want)
+	}
+}
+
+func TestDiskBufferDropsOldestSegment(t *testing.T) {
+	b, err := openDiskBuffer(t.TempDir(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close()
+
+	total, dropped := 0, 0
+	for i := range 40 {
+		n, err := b.append([]diskEvent{{Group: "test", Stream: "app", Timestamp: int64(i), Message: fmt.Sprintf("log %02d", i)}})
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		total++
+		dropped += n
+	}
+
+	if dropped == 0 {
+		t.Fatal("no events were dropped")
+	}
+	if b.size > b.maxBytes {
+		t.Errorf("buffer holds %d bytes, over its limit of %d", b.size, b.maxBytes)
+	}
+	kept := 0
+	for _, s := range b.segments {
+		kept += s.events
+	}
+	if kept+dropped != total {
+		t.Errorf("kept %d and dropped %d events, want %d in all", kept, dropped, total)
+	}
+
+	// The newest events survive
+	_, events, ok := b.oldest()
+	if !ok || len(events) == 0 || events[0].Timestamp != int64(dropped) {
+		t.Errorf("oldest segment starts with %v, want event %d", events, dropped)
+	}
+}
+
+func TestDiskBufferSkipsDamagedSegments(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, diskSegmentPrefix+name+diskSegmentSuffix), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1", `{"group":"test","stream":"app","timestamp":1,"message":"lost"}`+"\n")
+	write("2", "not json\n"+`{"group":"test","stream":"app","timestamp":2,"message":"kept"}`+"\n"+`{"group":"te`)
+
+	b, err := openDiskBuffer(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close()
+
+	// A file deleted behind the buffer's back has no events left to replay
+	missing, events, ok := b.oldest()
+	if err := os.Remove(missing); err != nil {
+		t.Fatal(err)
+	}
+	missing, events, ok = b.oldest()
+	if !ok || len(events) != 0 {
+		t.Fatalf("missing file returned %v, %v", events, ok)
+	}
+	if err := b.remove(missing); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	// Lines that are not JSON, or torn by a crash, are skipped
+	_, events, ok = b.oldest()
+	if !ok || len(events) != 1 || events[0].Message != "kept" {
+		t.Errorf("damaged file returned %v, %v, want only the valid event", events, ok)
+	}
+}
@@ -0,0 +1,243 @@
+package slogcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Limits of the CloudWatch Embedded Metric Format specification.
+const (
+	maxEMFNamespace  = 1024
+	maxEMFMetrics    = 100
+	maxEMFMetricName = 1024
+	maxEMFDimensions = 30
+	maxEMFDimKey     = 250
+	maxEMFDimValue   = 1024
+)
+
+// ErrInvalidMetric is returned by EmitMetric when the metrics do not form a
+// valid Embedded Metric Format document.
+var ErrInvalidMetric = errors.New("invalid CloudWatch metric")
+
+// MetricUnit is the unit of a Metric, one of the units CloudWatch supports.
+type MetricUnit string
+
+// Units of a Metric.
+const (
+	UnitNone               MetricUnit = "None"
+	UnitCount              MetricUnit = "Count"
+	UnitCountPerSecond     MetricUnit = "Count/Second"
+	UnitPercent            MetricUnit = "Percent"
+	UnitSeconds            MetricUnit = "Seconds"
+	UnitMilliseconds       MetricUnit = "Milliseconds"
+	UnitMicroseconds       MetricUnit = "Microseconds"
+	UnitBytes              MetricUnit = "Bytes"
+	UnitKilobytes          MetricUnit = "Kilobytes"
+	UnitMegabytes          MetricUnit = "Megabytes"
+	UnitGigabytes          MetricUnit = "Gigabytes"
+	UnitTerabytes          MetricUnit = "Terabytes"
+	UnitBits               MetricUnit = "Bits"
+	UnitKilobits           MetricUnit = "Kilobits"
+	UnitMegabits           MetricUnit = "Megabits"
+	UnitGigabits           MetricUnit = "Gigabits"
+	UnitTerabits           MetricUnit = "Terabits"
+	UnitBytesPerSecond     MetricUnit = "Bytes/Second"
+	UnitKilobytesPerSecond MetricUnit = "Kilobytes/Second"
+	UnitMegabytesPerSecond MetricUnit = "Megabytes/Second"
+	UnitGigabytesPerSecond MetricUnit = "Gigabytes/Second"
+	UnitTerabytesPerSecond MetricUnit = "Terabytes/Second"
+	UnitBitsPerSecond      MetricUnit = "Bits/Second"
+	UnitKilobitsPerSecond  MetricUnit = "Kilobits/Second"
+	UnitMegabitsPerSecond  MetricUnit = "Megabits/Second"
+	UnitGigabitsPerSecond  MetricUnit = "Gigabits/Second"
+	UnitTerabitsPerSecond  MetricUnit = "Terabits/Second"
+)
+
+// metricUnits is the set of units the Embedded Metric Format accepts.
+var metricUnits = map[MetricUnit]bool{
+	UnitNone: true, UnitCount: true, UnitCountPerSecond: true, UnitPercent: true,
+	UnitSeconds: true, UnitMilliseconds: true, UnitMicroseconds: true,
+	UnitBytes: true, UnitKilobytes: true, UnitMegabytes: true, UnitGigabytes: true, UnitTerabytes: true,
+	UnitBits: true, UnitKilobits: true, UnitMegabits: true, UnitGigabits: true, UnitTerabits: true,
+	UnitBytesPerSecond: true, UnitKilobytesPerSecond: true, UnitMegabytesPerSecond: true,
+	UnitGigabytesPerSecond: true, UnitTerabytesPerSecond: true,
+	UnitBitsPerSecond: true, UnitKilobitsPerSecond: true, UnitMegabitsPerSecond: true,
+	UnitGigabitsPerSecond: true, UnitTerabitsPerSecond: true,
+}
+
+// Metric is a value CloudWatch extracts as a metric from a log sent by
+// EmitMetric.
+type Metric struct {
+	Name  string
+	Value float64
+	// Unit defaults to UnitNone.
+	Unit MetricUnit
+	// HighResolution stores the metric at one-second instead of one-minute
+	// resolution, at a higher price.
+	HighResolution bool
+}
+
+// EmitMetric queues a log in the CloudWatch Embedded Metric Format, from which
+// CloudWatch creates the metrics under namespace, with dimensions as their
+// dimensions, without a separate call to the CloudWatch metrics API. The log
+// is sent to the client's log stream with the next batch. An error wrapping
+// ErrInvalidMetric is returned if the metrics break the format's rules, such
+// as more than 100 metrics or 30 dimensions, an unknown unit or a metric
+// named like a dimension.
+func (cw *CloudwatchClient) EmitMetric(namespace string, metrics []Metric, dimensions map[string]string) error {
+	return cw.EmitMetricCtx(context.Background(), namespace, metrics, dimensions)
+}
+
+// EmitMetricCtx is like EmitMetric but gives up with the context's error if
+// ctx is cancelled before the log is queued.
+func (cw *CloudwatchClient) EmitMetricCtx(ctx context.Context, namespace string, metrics []Metric, dimensions map[string]string) error {
+	now := cw.opts.clock.Now()
+	r := slog.NewRecord(now, slog.LevelInfo, "metrics", 0)
+	r.AddAttrs(slog.String("namespace", namespace))
+
+	message, err := marshalEMF(now.UnixMilli(), namespace, metrics, dimensions)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && len(message) > maxEventMessage {
+		err = fmt.Errorf("%w: %d bytes exceed the limit of %d for metrics in namespace %q", ErrMessageTooLarge, len(message), maxEventMessage, namespace)
+	}
+	if err != nil {
+		cw.opts.metrics.LogsFailed(1)
+		cw.reportError(err, r)
+		return err
+	}
+
+	qe := queuedEvent{
+		stream: cw.pickStream(r.Level),
+		event: types.InputLogEvent{
+			Message:   aws.String(string(message)),
+			Timestamp: aws.Int64(now.UnixMilli()),
+		},
+		record: r,
+	}
+	return cw.submit(ctx, qe)
+}
+
+// emfDirective is a CloudWatchMetrics entry of an Embedded Metric Format log.
+type emfDirective struct {
+	Namespace  string          `json:"Namespace"`
+	Dimensions [][]string      `json:"Dimensions"`
+	Metrics    []emfDefinition `json:"Metrics"`
+}
+
+// emfDefinition declares one of the metrics of an emfDirective.
+type emfDefinition struct {
+	Name              string     `json:"Name"`
+	Unit              MetricUnit `json:"Unit"`
+	StorageResolution int        `json:"StorageResolution,omitempty"`
+}
+
+// marshalEMF validates the metrics and returns the Embedded Metric Format log
+// that declares them, with the dimensions and metric values as top-level
+// fields next to the _aws metadata.
+func marshalEMF(timestamp int64, namespace string, metrics []Metric, dimensions map[string]string) ([]byte, error) {
+	if err := validateEMF(namespace, metrics, dimensions); err != nil {
+		return nil, err
+	}
+
+	// A metric without dimensions still needs an empty dimension set
+	keys := slices.AppendSeq(make([]string, 0, len(dimensions)), maps.Keys(dimensions))
+	slices.Sort(keys)
+	directive := emfDirective{
+		Namespace:  namespace,
+		Dimensions: [][]string{keys},
+		Metrics:    make([]emfDefinition, len(metrics)),
+	}
+
+	doc := make(map[string]any, len(dimensions)+len(metrics)+1)
+	for key, value := range dimensions {
+		doc[key] = value
+	}
+	for i, m := range metrics {
+		def := emfDefinition{Name: m.Name, Unit: unitOrNone(m.Unit)}
+		if m.HighResolution {
+			def.StorageResolution = 1
+		}
+		directive.Metrics[i] = def
+		doc[m.Name] = m.Value
+	}
+	doc["_aws"] = map[string]any{
+		"Timestamp":         timestamp,
+		"CloudWatchMetrics": []emfDirective{directive},
+	}
+	return json.Marshal(doc)
+}
+
+// unitOrNone returns unit, or UnitNone if it is empty.
+func unitOrNone(unit MetricUnit) MetricUnit {
+	if unit == "" {
+		return UnitNone
+	}
+	return unit
+}
+
+// hasKey reports whether m has an entry for key.
+func hasKey(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// validateEMF checks the metrics against the rules of the Embedded Metric
+// Format specification, which CloudWatch otherwise enforces by silently
+// ignoring the log.
+func validateEMF(namespace string, metrics []Metric, dimensions map[string]string) error {
+	switch {
+	case strings.TrimSpace(namespace) == "":
+		return fmt.Errorf("%w: namespace is empty", ErrInvalidMetric)
+	case len(namespace) > maxEMFNamespace:
+		return fmt.Errorf("%w: namespace is longer than %d characters", ErrInvalidMetric, maxEMFNamespace)
+	case len(metrics) == 0:
+		return fmt.Errorf("%w: no metrics in namespace %q", ErrInvalidMetric, namespace)
+	case len(metrics) > maxEMFMetrics:
+		return fmt.Errorf("%w: %d metrics exceed the limit of %d", ErrInvalidMetric, len(metrics), maxEMFMetrics)
+	case len(dimensions) > maxEMFDimensions:
+		return fmt.Errorf("%w: %d dimensions exceed the limit of %d", ErrInvalidMetric, len(dimensions), maxEMFDimensions)
+	}
+
+	for key, value := range dimensions {
+		switch {
+		case key == "" || len(key) > maxEMFDimKey:
+			return fmt.Errorf("%w: dimension name %q must be 1 to %d characters", ErrInvalidMetric, key, maxEMFDimKey)
+		case key == "_aws":
+			return fmt.Errorf("%w: dimension name %q is reserved", ErrInvalidMetric, key)
+		case value == "" || len(value) > maxEMFDimValue:
+			return fmt.Errorf("%w: value of dimension %q must be 1 to %d characters", ErrInvalidMetric, key, maxEMFDimValue)
+		}
+	}
+
+	seen := make(map[string]bool, len(metrics))
+	for _, m := range metrics {
+		switch {
+		case m.Name == "" || len(m.Name) > maxEMFMetricName:
+			return fmt.Errorf("%w: metric name %q must be 1 to %d characters", ErrInvalidMetric, m.Name, maxEMFMetricName)
+		case m.Name == "_aws":
+			return fmt.Errorf("%w: metric name %q is reserved", ErrInvalidMetric, m.Name)
+		case seen[m.Name]:
+			return fmt.Errorf("%w: metric %q is given more than once", ErrInvalidMetric, m.Name)
+		case hasKey(dimensions, m.Name):
+			return fmt.Errorf("%w: metric %q has the same name as a dimension", ErrInvalidMetric, m.Name)
+		case math.IsNaN(m.Value) || math.IsInf(m.Value, 0):
+			return fmt.Errorf("%w: metric %q has no finite value", ErrInvalidMetric, m.Name)
+		case m.Unit != "" && !metricUnits[m.Unit]:
+			return fmt.Errorf("%w: metric %q has unknown unit %q", ErrInvalidMetric, m.Name, m.Unit)
+		}
+		seen[m.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package slogcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Pooled buffers and log entries larger than this are dropped rather than
+// reused, so one huge log does not pin its memory for the life of the process.
+const (
+	maxPooledBufferSize = 64 << 10
+	maxPooledEntrySize  = 64
+)
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+var entryPool = sync.Pool{
+	New: func() any {
+		return make(map[string]interface{}, 8)
+	},
+}
+
+// errUnsupportedFloat is returned for NaN and infinite floats, which JSON cannot represent.
+var errUnsupportedFloat = errors.New("json: unsupported float value")
+
+// maxJSONDepth is how many maps deep appendJSONObject encodes itself. Deeper
+// values, including maps that contain themselves, are left to json.Marshal,
+// which detects cycles.
+const maxJSONDepth = 32
+
+// marshalEntry encodes entry as json.Marshal does, but writes the
+// common value types directly into a pooled buffer instead of going through
+// reflection. Values JSON cannot represent, such as channels, functions,
+// cyclic structures or NaN, are written as strings formatted with %+v instead
+// of failing the whole entry; the returned error then describes the first of
+// them, while the returned JSON is still valid.
+func marshalEntry(entry map[string]interface{}) ([]byte, error) {
+	bp := bufferPool.Get().(*[]byte)
+	defer func() {
+		if cap(*bp) <= maxPooledBufferSize {
+			bufferPool.Put(bp)
+		}
+	}()
+
+	buf, err := appendJSONObject((*bp)[:0], entry, 0)
+	*bp = buf
+	return slices.Clone(buf), err
+}
+
+// marshalBare encodes a log without attributes, holding only the message and
+// the level under levelKey, without building a map. The output is identical
+// to marshalEntry's for the same two fields.
+func marshalBare(message, levelKey string, level interface{}) ([]byte, error) {
+	buf := make([]byte, 0, len(message)+len(levelKey)+32)
+	buf = append(buf, '{')
+
+	// Keys in the order json.Marshal sorts them
+	var err error
+	if levelKey < "message" {
+		buf, err = appendJSONField(buf, levelKey, level, 0)
+		buf = append(buf, ',')
+		buf, _ = appendJSONField(buf, "message", message, 0)
+	} else {
+		buf, _ = appendJSONField(buf, "message", message, 0)
+		buf = append(buf, ',')
+		buf, err = appendJSONField(buf, levelKey, level, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(buf, '}'), nil
+}
+
+// appendJSONField appends "key":value, value being nested depth maps deep.
+func appendJSONField(buf []byte, key string, v interface{}, depth int) ([]byte, error) {
+	buf = appendJSONString(buf, key)
+	buf = append(buf, ':')
+	return appendJSONValue(buf, v, depth)
+}
+
+// newLogEntry returns an empty map from the pool to build a log entry in.
+func newLogEntry() map[string]interface{} {
+	return entryPool.Get().(map[string]interface{})
+}
+
+// releaseLogEntry returns entry to the pool once it has been encoded. Nested
+// group maps are not reused.
+func releaseLogEntry(entry map[string]interface{}) {
+	if len(entry) > maxPooledEntrySize {
+		return
+	}
+	clear(entry)
+	entryPool.Put(entry)
+}
+
+// appendJSONObject appends m, nested depth maps deep, with its keys sorted, as
+// json.Marshal encodes maps. It returns the first error of the values that had
+// to be replaced.
+func appendJSONObject(buf []byte, m map[string]interface{}, depth int) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var firstErr error
+	buf = append(buf, '{')
+	for i, k := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var err error
+		buf, err = appendJSONField(buf, k, m[k], depth+1)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("attribute %q: %w", k, err)
+		}
+	}
+	return append(buf, '}'), firstErr
+}
+
+// appendJSONValue appends v, falling back to json.Marshal for the types it
+// does not handle itself and for maps nested deeper than maxJSONDepth. Values
+// that cannot be encoded are appended as a string formatted with %+v, or as
+// the error for cyclic values, which %+v would format forever, along with the
+// error.
+func appendJSONValue(buf []byte, v interface{}, depth int) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case string:
+		return appendJSONString(buf, v), nil
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float64:
+		return appendJSONFloat(buf, v)
+	case time.Duration:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case map[string]interface{}:
+		if depth < maxJSONDepth {
+			return appendJSONObject(buf, v, depth)
+		}
+	}
+
+	data, err := json.Marshal(v)
+	var unsupported *json.UnsupportedValueError
+	if errors.As(err, &unsupported) && strings.HasPrefix(unsupported.Str, "encountered a cycle") {
+		return appendJSONString(buf, err.Error()), err
+	}
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprintf("%+v", v)), err
+	}
+	return append(buf, data...), nil
+}
+
+// appendJSONFloat appends f in the format json.Marshal uses for float64.
+func appendJSONFloat(buf []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, 64)), errUnsupportedFloat
+	}
+
+	// Like ES6, use exponents only for very small and very large numbers
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string, escaping it as json.Marshal
+// does: HTML characters and U+2028/U+2029 are escaped and invalid UTF-8 is
+// replaced by U+FFFD.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				buf = append(buf, '\\', b)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
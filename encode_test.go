@@ -0,0 +1,174 @@
+package slogcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalEntryMatchesJSONMarshal(t *testing.T) {
+	entries := map[string]map[string]interface{}{
+		"scalars": {
+			"message": "starting", "level": "INFO",
+			"int": int64(-42), "uint": uint64(math.MaxUint64), "bool": true, "nil": nil,
+			"duration": 1500 * time.Millisecond,
+		},
+		"floats": {
+			"zero": 0.0, "small": 1e-7, "large": 1e21, "fraction": 3.25, "negative": -2.5e-9, "int": 100.0,
+		},
+		"escaping": {
+			"quotes": `say "hi"\n`, "html": "<a href='x'>&</a>", "control": "tab\tnul\x00bell\a",
+			"separators": "line para ", "invalid": "bad\xffutf8", "unicode": "héllo 世界",
+			"key \"quoted\"": "v",
+		},
+		"nested": {
+			"user": map[string]interface{}{"id": int64(7), "tags": []string{"a", "b"}, "meta": map[string]interface{}{"x": 1.5}},
+		},
+		"fallback": {
+			"slice": []int{1, 2, 3}, "struct": struct{ A int }{1}, "time": time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for name, entry := range entries {
+		t.Run(name, func(t *testing.T) {
+			got, err := marshalEntry(entry)
+			if err != nil {
+				t.Fatalf("marshalEntry: %v", err)
+			}
+			want, err := json.Marshal(entry)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("marshalEntry = %s\njson.Marshal = %s", got, want)
+			}
+		})
+	}
+}
+
+func TestMarshalEntryUnsupportedValues(t *testing.T) {
+	cyclic := map[string]interface{}{"a": int64(1)}
+	cyclic["self"] = cyclic
+
+	tests := map[string]interface{}{
+		"NaN":          math.NaN(),
+		"channel":      make(chan int),
+		"cyclic map":   cyclic,
+		"cyclic slice": []interface{}{cyclic},
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := marshalEntry(map[string]interface{}{"message": "x", "value": value})
+			if err == nil {
+				t.Error("marshalEntry returned no error")
+			}
+			if !json.Valid(data) {
+				t.Errorf("marshalEntry returned invalid JSON: %.200s", data)
+			}
+		})
+	}
+}
+
+func TestHandlerLogsCyclicAttribute(t *testing.T) {
+	cw, fake := newTestClient(t)
+	cyclic := map[string]any{"a": 1}
+	cyclic["self"] = cyclic
+
+	slog.New(NewCloudWatchLogHandler(cw)).Info("cyclic", "m", cyclic)
+	slog.New(NewCloudWatchLogHandler(cw)).Info("after")
+	if err := cw.Close(); err != nil && !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("Close: %v", err)
+	}
+
+	messages := fake.messages()
+	if len(messages) != 2 || !strings.Contains(messages[0], "cycle") || !strings.Contains(messages[1], `"after"`) {
+		t.Errorf("sent %.300q", messages)
+	}
+}
+
+// benchRecord is a typical log with a handful of attributes.
+func benchRecord() slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "Order placed", 0)
+	r.AddAttrs(
+		slog.String("order_id", "o-12345"),
+		slog.Int("items", 3),
+		slog.Float64("total", 59.97),
+		slog.Bool("paid", true),
+		slog.Duration("latency", 42*time.Millisecond),
+		slog.Group("user", slog.Int("id", 42), slog.String("plan", "pro")),
+	)
+	return r
+}
+
+// BenchmarkFormatRecord compares building a new map and encoding it with
+// json.Marshal, as EmitLog used to, with the pooled map and encoder used now.
+func BenchmarkFormatRecord(b *testing.B) {
+	r := benchRecord()
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			entry := map[string]interface{}{"message": r.Message, DefaultLevelKey: levelString(r.Level)}
+			r.Attrs(func(a slog.Attr) bool {
+				addAttr(entry, a, errorFormat{})
+				return true
+			})
+			if _, err := json.Marshal(entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		cw, _ := newTestClient(b)
+		b.ReportAllocs()
+		for range b.N {
+			cw.formatDefault(r)
+		}
+	})
+}
+
+func TestMarshalBareMatchesMarshalEntry(t *testing.T) {
+	for _, message := range []string{"starting", "", `quote " and <html>`, "bad\xffutf8"} {
+		got, err := marshalBare(message, DefaultLevelKey, "INFO")
+		if err != nil {
+			t.Fatalf("marshalBare(%q): %v", message, err)
+		}
+		want, err := marshalEntry(map[string]interface{}{"message": message, DefaultLevelKey: "INFO"})
+		if err != nil {
+			t.Fatalf("marshalEntry(%q): %v", message, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("marshalBare = %s, marshalEntry = %s", got, want)
+		}
+	}
+}
+
+// BenchmarkFormatBareRecord compares the fast path for logs without
+// attributes with the general map-based path.
+func BenchmarkFormatBareRecord(b *testing.B) {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "starting", 0)
+
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			entry := buildLogEntry(r, DefaultLevelKey, errorFormat{})
+			if _, err := marshalEntry(entry); err != nil {
+				b.Fatal(err)
+			}
+			releaseLogEntry(entry)
+		}
+	})
+
+	b.Run("bare", func(b *testing.B) {
+		cw, _ := newTestClient(b)
+		b.ReportAllocs()
+		for range b.N {
+			cw.formatDefault(r)
+		}
+	})
+}
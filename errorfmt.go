@@ -0,0 +1,65 @@
+package slogcloud
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// errorFormat controls how error attributes are serialized.
+type errorFormat struct {
+	// details emits the concrete type and the unwrapped chain of the error
+	details bool
+	// stack emits the stack trace of errors that carry one
+	stack bool
+}
+
+// formatError returns the JSON value of err: its message, or an object with
+// the message and the requested details.
+func formatError(err error, ef errorFormat) any {
+	if !ef.details && !ef.stack {
+		return err.Error()
+	}
+
+	out := map[string]interface{}{
+		"message": err.Error(),
+		"type":    fmt.Sprintf("%T", err),
+	}
+
+	if ef.details {
+		var chain []map[string]interface{}
+		for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+			chain = append(chain, map[string]interface{}{
+				"message": e.Error(),
+				"type":    fmt.Sprintf("%T", e),
+			})
+		}
+		if len(chain) > 0 {
+			out["chain"] = chain
+		}
+	}
+
+	if ef.stack {
+		if stack := stackTrace(err); stack != "" {
+			out["stack"] = stack
+		}
+	}
+
+	return out
+}
+
+// stackTrace returns the stack trace of the first error in err's chain with a
+// StackTrace method, such as those created by github.com/pkg/errors. The
+// method's result is formatted with %+v, which prints one frame per line with
+// its file and line number.
+func stackTrace(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		m := reflect.ValueOf(e).MethodByName("StackTrace")
+		if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+			continue
+		}
+		return strings.TrimSpace(fmt.Sprintf("%+v", m.Call(nil)[0].Interface()))
+	}
+	return ""
+}
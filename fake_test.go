@@ -0,0 +1,128 @@
+package slogcloud
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// fakeCloudwatch is an in-memory CloudwatchAPI for tests. Every log group it
+// is asked about exists, and every PutLogEvents call is recorded and answered
+// by put if it is set, or accepted otherwise. Log streams are created by
+// createStream if it is set, and succeed otherwise. Calls to the methods it does not
+// implement panic on the nil embedded interface.
+type fakeCloudwatch struct {
+	CloudwatchAPI
+
+	mu    sync.Mutex
+	calls []*cloudwatchlogs.PutLogEventsInput
+	put   func(in *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error)
+
+	createStream func(ctx context.Context, in *cloudwatchlogs.CreateLogStreamInput) error
+
+	// retryers are the retryers PutLogEvents calls were made with
+	retryers []aws.Retryer
+}
+
+// newTestClient returns a client for the log group "test" backed by a
+// fakeCloudwatch, closed when the test ends.
+func newTestClient(t testing.TB, opts ...Option) (*CloudwatchClient, *fakeCloudwatch) {
+	t.Helper()
+	fake := &fakeCloudwatch{}
+	cw, err := NewCloudwatchClientWithAPI(fake, "test", opts...)
+	if err != nil {
+		t.Fatalf("NewCloudwatchClientWithAPI: %v", err)
+	}
+	t.Cleanup(func() { _ = cw.Close() })
+	return cw, fake
+}
+
+// setPut makes put answer the PutLogEvents calls from now on.
+func (f *fakeCloudwatch) setPut(put func(in *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.put = put
+}
+
+// setCreateStream makes createStream create the log streams from now on.
+func (f *fakeCloudwatch) setCreateStream(createStream func(ctx context.Context, in *cloudwatchlogs.CreateLogStreamInput) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.createStream = createStream
+}
+
+// putCalls returns the PutLogEvents calls made so far.
+func (f *fakeCloudwatch) putCalls() []*cloudwatchlogs.PutLogEventsInput {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// messages returns the messages of every event sent, in the order they were sent.
+func (f *fakeCloudwatch) messages() []string {
+	var messages []string
+	for _, in := range f.putCalls() {
+		for _, e := range in.LogEvents {
+			messages = append(messages, aws.ToString(e.Message))
+		}
+	}
+	return messages
+}
+
+// entries returns every event sent decoded from JSON.
+func (f *fakeCloudwatch) entries(t testing.TB) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, message := range f.messages() {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(message), &entry); err != nil {
+			t.Fatalf("event %q is not JSON: %v", message, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (f *fakeCloudwatch) PutLogEvents(_ context.Context, in *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	opts := cloudwatchlogs.Options{Retryer: retry.NewStandard()}
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	f.retryers = append(f.retryers, opts.Retryer)
+	call := *in
+	call.LogEvents = slices.Clone(in.LogEvents)
+	f.calls = append(f.calls, &call)
+	if f.put != nil {
+		return f.put(&call)
+	}
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+func (f *fakeCloudwatch) DescribeLogGroups(_ context.Context, in *cloudwatchlogs.DescribeLogGroupsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	return &cloudwatchlogs.DescribeLogGroupsOutput{LogGroups: []types.LogGroup{{LogGroupName: in.LogGroupNamePattern}}}, nil
+}
+
+func (f *fakeCloudwatch) CreateLogGroup(context.Context, *cloudwatchlogs.CreateLogGroupInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (f *fakeCloudwatch) CreateLogStream(ctx context.Context, in *cloudwatchlogs.CreateLogStreamInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	f.mu.Lock()
+	createStream := f.createStream
+	f.mu.Unlock()
+	if createStream != nil {
+		if err := createStream(ctx, in); err != nil {
+			return nil, err
+		}
+	}
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
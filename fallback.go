@@ -0,0 +1,33 @@
+package slogcloud
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// FallbackWrites returns the number of events written to the fallback writer
+// because they could not be sent to CloudWatch.
+func (cw *CloudwatchClient) FallbackWrites() uint64 {
+	return cw.fallbackWrites.Load()
+}
+
+// writeFallback writes events that could not be sent to CloudWatch to the
+// fallback writer, one JSON log per line, in the same shape they would have
+// had in CloudWatch.
+func (cw *CloudwatchClient) writeFallback(events []queuedEvent) {
+	if cw.opts.fallback == nil {
+		return
+	}
+
+	// Streams are flushed in parallel, so writes are serialized to keep lines whole
+	cw.fallbackMu.Lock()
+	defer cw.fallbackMu.Unlock()
+
+	for _, qe := range events {
+		line := append([]byte(aws.ToString(qe.event.Message)), '\n')
+		if _, err := cw.opts.fallback.Write(line); err != nil {
+			cw.opts.debugf("Failed to write log event to the fallback writer: %v", err)
+			continue
+		}
+		cw.fallbackWrites.Add(1)
+	}
+}
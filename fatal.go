@@ -0,0 +1,78 @@
+package slogcloud
+
+import (
+	"os"
+	"sync"
+)
+
+// fatalExit holds what happens after a logger's Fatal has logged its message.
+// It is embedded in the loggers so they share OnFatal, SetExitFunc and
+// SetExitCode.
+type fatalExit struct {
+	mu    sync.Mutex
+	hooks []func()
+	exit  func(code int)
+
+	// code is the exit code of Fatal when codeSet, and 1 otherwise
+	code    int
+	codeSet bool
+}
+
+// OnFatal registers a hook that runs after Fatal has logged its message and
+// before the program exits, in the order hooks were registered.
+func (f *fatalExit) OnFatal(hook func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = append(f.hooks, hook)
+}
+
+// SetExitFunc replaces os.Exit as the function Fatal calls last, so tests can
+// observe a fatal error without terminating the test binary.
+func (f *fatalExit) SetExitFunc(exit func(code int)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exit = exit
+}
+
+// SetExitCode sets the code Fatal exits with, 1 unless changed, for
+// orchestrators that tell failures apart by exit status. FatalCode picks the
+// code for a single call instead.
+func (f *fatalExit) SetExitCode(code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.code = code
+	f.codeSet = true
+}
+
+// exitCode returns the code Fatal exits with.
+func (f *fatalExit) exitCode() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.codeSet {
+		return 1
+	}
+	return f.code
+}
+
+// runHooks runs the registered OnFatal hooks.
+func (f *fatalExit) runHooks() {
+	f.mu.Lock()
+	hooks := f.hooks
+	f.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// exitWith calls the exit function, os.Exit unless replaced.
+func (f *fatalExit) exitWith(code int) {
+	f.mu.Lock()
+	exit := f.exit
+	f.mu.Unlock()
+
+	if exit == nil {
+		exit = os.Exit
+	}
+	exit(code)
+}
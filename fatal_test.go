@@ -0,0 +1,36 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+// ctxSink records the context error seen by each record it is given.
+type ctxSink struct {
+	errs []error
+}
+
+func (s *ctxSink) Emit(ctx context.Context, _ slog.Record) error {
+	s.errs = append(s.errs, ctx.Err())
+	return nil
+}
+
+func TestFatalContextIgnoresCancellation(t *testing.T) {
+	sink := &ctxSink{}
+	logger := NewLoggerWithSink(sink)
+	var code int
+	logger.SetExitFunc(func(c int) { code = c })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger.FatalContext(ctx, "shutting down", errors.New("boom"))
+
+	if code != 1 {
+		t.Errorf("exited with %d, want 1", code)
+	}
+	if len(sink.errs) != 1 || sink.errs[0] != nil {
+		t.Errorf("fatal log emitted with context errors %v, want one log with no error", sink.errs)
+	}
+}
@@ -0,0 +1,60 @@
+package slogcloud
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// Fields is a set of attributes given as a map, as logrus does. It can be
+// passed to any Logger method in place of key/value pairs, or bound with
+// WithFields:
+//
+//	logger.Info("User logged in", slogcloud.Fields{"user_id": 42, "plan": "pro"})
+//
+// Nested Fields and map[string]any values become nested JSON objects.
+type Fields map[string]any
+
+// Attrs returns the fields as attributes sorted by key, with nested Fields
+// and map[string]any values turned into groups.
+func (f Fields) Attrs() []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(f))
+	for _, key := range slices.Sorted(maps.Keys(f)) {
+		switch v := f[key].(type) {
+		case Fields:
+			attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(v.Attrs()...)})
+		case map[string]any:
+			attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(Fields(v).Attrs()...)})
+		default:
+			attrs = append(attrs, slog.Any(key, v))
+		}
+	}
+	return attrs
+}
+
+// expandFields returns args with every Fields replaced by its attributes, so
+// slog does not treat it as a key without a value.
+func expandFields(args []any) []any {
+	if !slices.ContainsFunc(args, isFields) {
+		return args
+	}
+
+	expanded := make([]any, 0, len(args))
+	for _, arg := range args {
+		f, ok := arg.(Fields)
+		if !ok {
+			expanded = append(expanded, arg)
+			continue
+		}
+		for _, a := range f.Attrs() {
+			expanded = append(expanded, a)
+		}
+	}
+	return expanded
+}
+
+// isFields reports whether arg is a Fields.
+func isFields(arg any) bool {
+	_, ok := arg.(Fields)
+	return ok
+}
@@ -0,0 +1,200 @@
+package slogcloud
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Formatter turns a record into the JSON document sent to CloudWatch, to
+// match the field names an aggregator expects.
+//
+// attrs holds the attributes of r, with LogValuers resolved, redaction and
+// error formatting applied and groups as nested maps; read attributes from it
+// rather than from r. Format may modify attrs. If Format returns an error the
+// log is sent in the default format instead.
+type Formatter interface {
+	Format(r slog.Record, attrs map[string]any) ([]byte, error)
+}
+
+// JSONFormatter is the default format: the message under "message", the
+// level under LevelKey and the attributes as top-level fields.
+type JSONFormatter struct {
+	// LevelKey is the key of the level. Empty means DefaultLevelKey.
+	LevelKey string
+	// LevelValue maps a level to the value stored under LevelKey, as in
+	// WithLevelValue. Nil means the level name, such as "INFO".
+	LevelValue func(slog.Level) any
+}
+
+// Format stores the message and level next to attrs and encodes them.
+// Attributes named like the message or level key take precedence. Values JSON
+// cannot represent are written as text.
+func (f JSONFormatter) Format(r slog.Record, attrs map[string]any) ([]byte, error) {
+	levelKey := cmp.Or(f.LevelKey, DefaultLevelKey)
+	if _, ok := attrs["message"]; !ok {
+		attrs["message"] = r.Message
+	}
+	if _, ok := attrs[levelKey]; !ok {
+		if f.LevelValue != nil {
+			attrs[levelKey] = f.LevelValue(r.Level)
+		} else {
+			attrs[levelKey] = levelString(r.Level)
+		}
+	}
+	data, _ := marshalEntry(attrs)
+	return data, nil
+}
+
+// ECSVersion is the version of the Elastic Common Schema ECSFormatter follows.
+const ECSVersion = "8.11.0"
+
+// ECSFormatter formats records in the Elastic Common Schema used by
+// Elasticsearch and Kibana: "@timestamp", "log.level", "message" and
+// "ecs.version", with the attributes as further fields. An "error" attribute
+// becomes the ECS error object with its message and, when WithErrorDetails or
+// WithErrorStackTraces are used, its type and stack trace.
+type ECSFormatter struct{}
+
+// Format encodes r and attrs as an ECS document.
+func (ECSFormatter) Format(r slog.Record, attrs map[string]any) ([]byte, error) {
+	if err, ok := attrs["error"]; ok {
+		attrs["error"] = ecsError(err)
+	}
+	if !r.Time.IsZero() {
+		attrs["@timestamp"] = r.Time.UTC().Format(time.RFC3339Nano)
+	}
+	attrs["log.level"] = strings.ToLower(levelString(r.Level))
+	attrs["message"] = r.Message
+	attrs["ecs.version"] = ECSVersion
+
+	data, _ := marshalEntry(attrs)
+	return data, nil
+}
+
+// ecsError converts an error attribute, as produced by formatError, into the
+// ECS error fields.
+func ecsError(v any) any {
+	switch err := v.(type) {
+	case string:
+		return map[string]any{"message": err}
+	case map[string]any:
+		out := map[string]any{"message": err["message"], "type": err["type"]}
+		if stack, ok := err["stack"]; ok {
+			out["stack_trace"] = stack
+		}
+		return out
+	}
+	return v
+}
+
+// recordAttrs collects the attributes of r for a Formatter.
+func recordAttrs(r slog.Record, ef errorFormat) map[string]any {
+	attrs := make(map[string]any, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(attrs, a, ef)
+		return true
+	})
+	return attrs
+}
+
+// formatWith encodes r with the configured Formatter, shrinking it according
+// to the oversize policy when it exceeds the maximum size. The shrunk log is
+// formatted by the Formatter too, so it keeps the same shape.
+func (cw *CloudwatchClient) formatWith(f Formatter, r slog.Record) []byte {
+	data, ok := cw.tryFormat(f, r, recordAttrs(r, cw.opts.errorFormat))
+	if !ok {
+		return cw.formatDefault(r)
+	}
+
+	limit := cw.messageLimit()
+	if limit <= 0 || len(data) <= limit {
+		return data
+	}
+
+	switch cw.opts.oversizePolicy {
+	case OversizeDropAttrs:
+		if shrunk, ok := cw.dropFormattedAttrs(f, r, limit); ok {
+			return shrunk
+		}
+	case OversizeCompress:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		bare := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		shrunk, ok := cw.tryFormat(f, bare, map[string]any{"compressed": base64.StdEncoding.EncodeToString(buf.Bytes())})
+		if ok && len(shrunk) <= limit {
+			return shrunk
+		}
+	}
+
+	// Keep only the message, shortening it by the overshoot until it fits.
+	// If the Formatter fails on the shortened log, which tryFormat reports,
+	// the default format shrinks it instead.
+	message := r.Message
+	for {
+		bare := slog.NewRecord(r.Time, r.Level, message, r.PC)
+		shrunk, ok := cw.tryFormat(f, bare, map[string]any{"truncated": true})
+		if !ok {
+			return cw.formatDefault(r)
+		}
+		if len(shrunk) <= limit || message == "" {
+			return shrunk
+		}
+		message = truncateString(message, len(message)-(len(shrunk)-limit))
+	}
+}
+
+// dropFormattedAttrs removes the largest attributes of r until it fits in limit.
+func (cw *CloudwatchClient) dropFormattedAttrs(f Formatter, r slog.Record, limit int) ([]byte, bool) {
+	attrs := recordAttrs(r, cw.opts.errorFormat)
+
+	type sizedKey struct {
+		key  string
+		size int
+	}
+	var keys []sizedKey
+	for key, val := range attrs {
+		encoded, _ := json.Marshal(val)
+		keys = append(keys, sizedKey{key: key, size: len(key) + len(encoded)})
+	}
+	slices.SortFunc(keys, func(a, b sizedKey) int {
+		return cmp.Compare(b.size, a.size)
+	})
+
+	var dropped []string
+	for _, k := range keys {
+		delete(attrs, k.key)
+		dropped = append(dropped, k.key)
+
+		// Format may modify the map it is given
+		remaining := make(map[string]any, len(attrs)+1)
+		for key, val := range attrs {
+			remaining[key] = val
+		}
+		remaining["dropped_attrs"] = dropped
+
+		data, ok := cw.tryFormat(f, r, remaining)
+		if ok && len(data) <= limit {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// tryFormat calls the Formatter, reporting whether it succeeded.
+func (cw *CloudwatchClient) tryFormat(f Formatter, r slog.Record, attrs map[string]any) ([]byte, bool) {
+	data, err := f.Format(r, attrs)
+	if err != nil {
+		cw.opts.debugf("Formatter failed on log %q, sending it in the default format: %v", r.Message, err)
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,76 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Errors wrapped by Healthcheck to tell why CloudWatch is not usable.
+var (
+	// ErrUnauthorized means the credentials are missing, invalid, expired or
+	// lack permission for the log group.
+	ErrUnauthorized = errors.New("not authorized to use CloudWatch Logs")
+	// ErrUnreachable means CloudWatch could not be reached, for example
+	// because of DNS, network or timeout failures.
+	ErrUnreachable = errors.New("CloudWatch Logs is unreachable")
+	// ErrLogStreamNotFound means the client's log group or stream no longer
+	// exists.
+	ErrLogStreamNotFound = errors.New("log group or stream not found")
+)
+
+// authErrorCodes are the API error codes CloudWatch returns for requests it
+// does not accept the credentials of.
+var authErrorCodes = map[string]bool{
+	"AccessDeniedException":       true,
+	"UnrecognizedClientException": true,
+	"InvalidSignatureException":   true,
+	"ExpiredTokenException":       true,
+	"MissingAuthenticationToken":  true,
+}
+
+// Healthcheck reports whether the client can reach CloudWatch and write to its
+// log stream, for example for a readiness probe. It makes a single
+// DescribeLogStreams call for the client's first stream, which needs the
+// logs:DescribeLogStreams permission, and sends no log. The error wraps
+// ErrUnauthorized, ErrUnreachable or ErrLogStreamNotFound when the failure is
+// one of those. Bound the time it may take with ctx.
+func (cw *CloudwatchClient) Healthcheck(ctx context.Context) error {
+	logStream := cw.currentLogStream()
+	output, err := cw.client.DescribeLogStreams(ctx, &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName:        aws.String(cw.logGroup),
+		LogStreamNamePrefix: aws.String(logStream),
+		Limit:               aws.Int32(1),
+	})
+	if err != nil {
+		return healthError(err)
+	}
+	for _, stream := range output.LogStreams {
+		if aws.ToString(stream.LogStreamName) == logStream {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: log stream %s in group %s", ErrLogStreamNotFound, logStream, cw.logGroup)
+}
+
+// healthError wraps err, returned by a CloudWatch call, in the Healthcheck
+// error that describes it.
+func healthError(err error) error {
+	var notFound *types.ResourceNotFoundException
+	var apiErr interface{ ErrorCode() string }
+	var netErr net.Error
+	switch {
+	case errors.As(err, &notFound):
+		return fmt.Errorf("%w: %w", ErrLogStreamNotFound, err)
+	case errors.As(err, &apiErr) && authErrorCodes[apiErr.ErrorCode()]:
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	return fmt.Errorf("CloudWatch health check failed: %w", err)
+}
@@ -0,0 +1,80 @@
+package slogcloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// ParseLevel parses a level name such as "debug", "info", "warn", "error" or
+// "fatal", ignoring case, into a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "fatal":
+		return LevelFatal, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// SyslogSeverity maps level to its numeric syslog severity (RFC 5424): 7 for
+// debug, 6 for info, 4 for warning, 3 for error and 2 (critical) for fatal.
+// Use it with WithLevelValue.
+func SyslogSeverity(level slog.Level) any {
+	switch {
+	case level >= LevelFatal:
+		return 2
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// levelValue returns the value stored under the level key for level.
+func (cw *CloudwatchClient) levelValue(level slog.Level) any {
+	if cw.opts.levelValue != nil {
+		return cw.opts.levelValue(level)
+	}
+	return levelString(level)
+}
+
+// WithLevelFromEnv sets the minimum level of records sent to CloudWatch from
+// the environment variable name, for example SLOGCLOUD_LEVEL=warn, so the
+// verbosity can be changed without changing code. The variable is read when
+// the handler is created and parsed with ParseLevel. If it is unset the level
+// is left unchanged; if it holds an invalid value the level is set to
+// slog.LevelInfo and a warning is logged.
+func WithLevelFromEnv(name string) HandlerOption {
+	return func(h *CloudWatchLogHandler) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return
+		}
+
+		level, err := ParseLevel(value)
+		if err == nil {
+			h.level.Set(level)
+			return
+		}
+
+		h.level.Set(slog.LevelInfo)
+		r := slog.NewRecord(time.Now(), slog.LevelWarn, "Invalid log level in environment, defaulting to info", 0)
+		r.AddAttrs(slog.String("variable", name), slog.String("value", value))
+		_ = h.sink.Emit(context.Background(), r)
+	}
+}
@@ -0,0 +1,65 @@
+package slogcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// maxLevelBody caps the size of a request body accepted by LevelHandler.
+const maxLevelBody = 1024
+
+// LevelHandler returns an http.Handler that reads and changes level at
+// runtime, so verbosity can be raised in production without a redeploy.
+// Mount it on an admin endpoint, as it is not authenticated:
+//
+//	http.Handle("/admin/log-level", slogcloud.LevelHandler(handler.LevelVar()))
+//
+// GET responds with the current level as {"level":"INFO"}. PUT and POST set
+// the level from the body, either a level name such as "debug", parsed with
+// ParseLevel, or a JSON object like the GET response, and respond with the
+// new level.
+func LevelHandler(level *slog.LevelVar) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			newLevel, err := readLevel(http.MaxBytesReader(w, req.Body, maxLevelBody))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level.Set(newLevel)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"level": levelString(level.Level())})
+	})
+}
+
+// readLevel parses the level sent to LevelHandler.
+func readLevel(body io.Reader) (slog.Level, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return 0, fmt.Errorf("could not read level: %w", err)
+	}
+
+	s := strings.TrimSpace(string(data))
+	if strings.HasPrefix(s, "{") {
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.Unmarshal([]byte(s), &req); err != nil {
+			return 0, fmt.Errorf("invalid level request: %w", err)
+		}
+		s = req.Level
+	}
+	return ParseLevel(s)
+}
@@ -0,0 +1,338 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// DefaultLogGroupWait is how long the client waits for a newly created log
+// group to become visible before creating its log stream.
+const DefaultLogGroupWait = 5 * time.Second
+
+// logGroupPollInterval is the delay between checks for a newly created log group.
+const logGroupPollInterval = 250 * time.Millisecond
+
+// maxLogStreamNameLength is the longest log stream name CloudWatch accepts.
+const maxLogStreamNameLength = 512
+
+// maxLogGroupNameLength is the longest log group name CloudWatch accepts.
+const maxLogGroupNameLength = 512
+
+// logGroupNamePattern matches the characters CloudWatch allows in log group names.
+var logGroupNamePattern = regexp.MustCompile(`^[.\-_/#A-Za-z0-9]+$`)
+
+// ensureLogGroup creates logGroup if it doesn't exist yet and applies the
+// configured tags, KMS key and retention policy. When log group creation is
+// disabled, the group is assumed to exist and is left as it is.
+func ensureLogGroup(ctx context.Context, cwClient CloudwatchAPI, logGroup string, o options) error {
+	if !o.createLogGroup {
+		if len(o.tags) > 0 || o.kmsKey != "" || o.retentionDays != nil {
+			o.debugf("Log group creation is disabled, ignoring the tags, KMS key and retention of log group %s", logGroup)
+		}
+		return nil
+	}
+
+	// Explicitly check if the exact log group exists
+	exists := false
+	var retention *int32
+	var kmsKey *string
+	output, err := cwClient.DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePattern: aws.String(logGroup),
+	})
+	if err != nil {
+		o.debugf("Error checking log group existence: %v", err)
+	} else {
+		for _, group := range output.LogGroups {
+			if aws.ToString(group.LogGroupName) == logGroup {
+				exists = true
+				retention = group.RetentionInDays
+				kmsKey = group.KmsKeyId
+				break
+			}
+		}
+	}
+
+	// If the log group doesn't exist, create it
+	if !exists {
+		o.debugf("Log group %s does not exist, creating...", logGroup)
+		input := &cloudwatchlogs.CreateLogGroupInput{
+			LogGroupName: aws.String(logGroup),
+		}
+		if len(o.tags) > 0 {
+			input.Tags = o.tags
+		}
+		if o.kmsKey != "" {
+			input.KmsKeyId = aws.String(o.kmsKey)
+		}
+		_, err = cwClient.CreateLogGroup(ctx, input)
+		if err != nil {
+			return kmsError(fmt.Errorf("failed to create log group: %w", err), o.kmsKey)
+		}
+		o.debugf("Log group %s created successfully", logGroup)
+
+		waitForLogGroup(ctx, cwClient, logGroup, o)
+	} else {
+		o.debugf("Log group %s already exists", logGroup)
+		if err := applyTags(ctx, cwClient, logGroup, o.tags); err != nil {
+			return err
+		}
+		if err := applyKMSKey(ctx, cwClient, logGroup, kmsKey, o.kmsKey); err != nil {
+			return err
+		}
+	}
+
+	if o.retentionDays != nil {
+		return applyRetention(ctx, cwClient, logGroup, retention, *o.retentionDays)
+	}
+	return nil
+}
+
+// waitForLogGroup polls until a newly created log group is visible, as
+// CloudWatch is eventually consistent, or until the configured wait elapses.
+// Giving up is not an error: creating the log stream is retried anyway.
+func waitForLogGroup(ctx context.Context, cwClient CloudwatchAPI, logGroup string, o options) {
+	if o.logGroupWait <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(o.logGroupWait)
+	for {
+		output, err := cwClient.DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
+			LogGroupNamePattern: aws.String(logGroup),
+		})
+		if err == nil && slices.ContainsFunc(output.LogGroups, func(group types.LogGroup) bool {
+			return aws.ToString(group.LogGroupName) == logGroup
+		}) {
+			return
+		}
+
+		if time.Now().Add(logGroupPollInterval).After(deadline) {
+			o.debugf("Log group %s is not visible after %s, continuing anyway", logGroup, o.logGroupWait)
+			return
+		}
+		if sleepCtx(ctx, logGroupPollInterval) != nil {
+			return
+		}
+	}
+}
+
+// Limits CloudWatch enforces on log group tags.
+const (
+	maxTags           = 50
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// validateTags checks tags against CloudWatch's tagging limits.
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("invalid log group tags: %d tags given, at most %d are allowed", len(tags), maxTags)
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > maxTagKeyLength {
+			return fmt.Errorf("invalid log group tag key %q: must be 1 to %d characters", key, maxTagKeyLength)
+		}
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return fmt.Errorf("invalid log group tag key %q: the aws: prefix is reserved", key)
+		}
+		if len(value) > maxTagValueLength {
+			return fmt.Errorf("invalid value for log group tag %q: longer than %d characters", key, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// applyTags adds tags to an existing log group.
+func applyTags(ctx context.Context, cwClient CloudwatchAPI, logGroup string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := cwClient.TagLogGroup(ctx, &cloudwatchlogs.TagLogGroupInput{
+		LogGroupName: aws.String(logGroup),
+		Tags:         tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tag log group %s: %w", logGroup, err)
+	}
+	return nil
+}
+
+// retentionDays lists the retention periods CloudWatch accepts for a log group.
+var retentionDays = []int32{
+	1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545,
+	731, 1096, 1827, 2192, 2557, 2922, 3288, 3653,
+}
+
+// validRetention reports whether days is 0 (never expire) or a retention
+// period CloudWatch accepts.
+func validRetention(days int32) bool {
+	return days == 0 || slices.Contains(retentionDays, days)
+}
+
+// applyRetention updates the retention policy of logGroup when it differs from
+// the wanted number of days. current is nil when the group never expires.
+func applyRetention(ctx context.Context, cwClient CloudwatchAPI, logGroup string, current *int32, days int32) error {
+	if days == 0 {
+		if current == nil {
+			return nil
+		}
+		_, err := cwClient.DeleteRetentionPolicy(ctx, &cloudwatchlogs.DeleteRetentionPolicyInput{
+			LogGroupName: aws.String(logGroup),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove log group retention policy: %w", err)
+		}
+		return nil
+	}
+
+	if current != nil && *current == days {
+		return nil
+	}
+	_, err := cwClient.PutRetentionPolicy(ctx, &cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    aws.String(logGroup),
+		RetentionInDays: aws.Int32(days),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set log group retention policy: %w", err)
+	}
+	return nil
+}
+
+// applyKMSKey encrypts logGroup with keyARN unless it already is. current is
+// the key the group is known to use, if any.
+func applyKMSKey(ctx context.Context, cwClient CloudwatchAPI, logGroup string, current *string, keyARN string) error {
+	if keyARN == "" || aws.ToString(current) == keyARN {
+		return nil
+	}
+
+	_, err := cwClient.AssociateKmsKey(ctx, &cloudwatchlogs.AssociateKmsKeyInput{
+		LogGroupName: aws.String(logGroup),
+		KmsKeyId:     aws.String(keyARN),
+	})
+	if err != nil {
+		return kmsError(fmt.Errorf("failed to associate KMS key with log group %s: %w", logGroup, err), keyARN)
+	}
+	return nil
+}
+
+// kmsError adds the permissions a KMS key needs to permission errors, which
+// CloudWatch reports as access denied or as an invalid key parameter.
+func kmsError(err error, keyARN string) error {
+	if keyARN == "" {
+		return err
+	}
+	var denied *types.AccessDeniedException
+	var invalid *types.InvalidParameterException
+	if !errors.As(err, &denied) && !errors.As(err, &invalid) {
+		return err
+	}
+	return fmt.Errorf("%w (check that KMS key %s exists in the log group's region, that its key policy allows the logs.<region>.amazonaws.com service principal to use it, and that the caller may call logs:AssociateKmsKey)", err, keyARN)
+}
+
+// createLogStream creates logStream in logGroup, retrying transient failures.
+// A stream that already exists, for example one with a fixed name written to
+// before a restart, is reused and its sequence token returned.
+func createLogStream(ctx context.Context, cwClient CloudwatchAPI, logGroup, logStream string, o options) (*string, error) {
+	o.debugf("Creating log stream %s in group %s", logStream, logGroup)
+
+	// Create the log stream with retries
+	var lastErr error
+	for i := 0; i < o.streamAttempts; i++ {
+		_, err := cwClient.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+			LogGroupName:  aws.String(logGroup),
+			LogStreamName: aws.String(logStream),
+		})
+		if err == nil {
+			o.debugf("Log stream %s is ready", logStream)
+			return nil, nil
+		}
+		var alreadyExists *types.ResourceAlreadyExistsException
+		if errors.As(err, &alreadyExists) {
+			o.debugf("Log stream %s already exists, appending to it", logStream)
+			return resumeLogStream(ctx, cwClient, logGroup, logStream, o), nil
+		}
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("log group %s does not exist: %w", logGroup, err)
+		}
+		lastErr = err
+		o.debugf("Attempt %d: Failed to create log stream: %v", i+1, err)
+		if i == o.streamAttempts-1 {
+			break
+		}
+		if err := sleepCtx(ctx, jitter(o.streamRetryDelay, o.streamRetryJitter)); err != nil {
+			return nil, fmt.Errorf("gave up creating CloudWatch log stream: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to create CloudWatch log stream after %d attempts: %w", o.streamAttempts, lastErr)
+}
+
+// sleepCtx waits for d, returning early with the context's error if ctx is
+// done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resumeLogStream looks up the sequence token of an existing log stream so
+// appending to it does not start with a rejected call. CloudWatch no longer
+// requires sequence tokens, so a failed lookup is not an error: a nil token
+// is corrected on the first PutLogEvents call if needed.
+func resumeLogStream(ctx context.Context, cwClient CloudwatchAPI, logGroup, logStream string, o options) *string {
+	output, err := cwClient.DescribeLogStreams(ctx, &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName:        aws.String(logGroup),
+		LogStreamNamePrefix: aws.String(logStream),
+	})
+	if err != nil {
+		o.debugf("Could not describe log stream %s: %v", logStream, err)
+		return nil
+	}
+	for _, stream := range output.LogStreams {
+		if aws.ToString(stream.LogStreamName) == logStream {
+			return stream.UploadSequenceToken
+		}
+	}
+	return nil
+}
+
+// validateLogGroupName checks name against CloudWatch's naming rules.
+func validateLogGroupName(name string) error {
+	if name == "" {
+		return errors.New("invalid log group name: must not be empty")
+	}
+	if len(name) > maxLogGroupNameLength {
+		return fmt.Errorf("invalid log group name %q: longer than %d characters", name, maxLogGroupNameLength)
+	}
+	if !logGroupNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid log group name %q: may only contain letters, digits and '.', '-', '_', '/', '#'", name)
+	}
+	return nil
+}
+
+// validateLogStreamName checks name against CloudWatch's naming rules.
+func validateLogStreamName(name string) error {
+	if len(name) > maxLogStreamNameLength {
+		return fmt.Errorf("invalid log stream name %q: longer than %d characters", name, maxLogStreamNameLength)
+	}
+	if strings.ContainsAny(name, ":*") {
+		return fmt.Errorf("invalid log stream name %q: must not contain ':' or '*'", name)
+	}
+	return nil
+}
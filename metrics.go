@@ -0,0 +1,137 @@
+package slogcloud
+
+// DropReason tells why a log was dropped before it was sent.
+type DropReason string
+
+const (
+	// DropSampled is a log rejected by the handler's Sampler.
+	DropSampled DropReason = "sampled"
+	// DropQueueFull is a log discarded by OverflowDropNewest or OverflowDropOldest.
+	DropQueueFull DropReason = "queue_full"
+	// DropCircuitOpen is a log not sent because the circuit breaker is open.
+	// It is still written to the fallback writer, if there is one.
+	DropCircuitOpen DropReason = "circuit_open"
+	// DropDiskBufferFull is a log deleted from the disk buffer of
+	// WithDiskBuffer to stay within its maximum size.
+	DropDiskBufferFull DropReason = "disk_buffer_full"
+)
+
+// Metrics receives counts of what the client does, so the logger itself can
+// be monitored, for example to alert when sending to CloudWatch fails.
+// Methods may be called concurrently and from the background goroutine, and
+// must not block.
+type Metrics interface {
+	// LogEmitted is called for every log queued to be sent.
+	LogEmitted()
+	// LogDropped is called for every log dropped on purpose.
+	LogDropped(reason DropReason)
+	// LogsFailed is called with the number of logs that could not be queued
+	// or sent, for example because every retry of PutLogEvents failed.
+	LogsFailed(n int)
+	// BatchSent is called after each successful PutLogEvents call with the
+	// number of events and message bytes it sent.
+	BatchSent(events, bytes int)
+	// Retry is called before every retry of a failed PutLogEvents call.
+	Retry()
+	// CircuitStateChanged is called whenever the circuit breaker enabled by
+	// WithCircuitBreaker changes state.
+	CircuitStateChanged(state CircuitState)
+}
+
+// NopMetrics is a Metrics that discards everything. It is the default.
+type NopMetrics struct{}
+
+func (NopMetrics) LogEmitted()                      {}
+func (NopMetrics) LogDropped(DropReason)            {}
+func (NopMetrics) LogsFailed(int)                   {}
+func (NopMetrics) BatchSent(int, int)               {}
+func (NopMetrics) Retry()                           {}
+func (NopMetrics) CircuitStateChanged(CircuitState) {}
+
+// Counter is a monotonically increasing metric. It is satisfied by
+// prometheus.Counter and by the counters of most other metrics libraries.
+type Counter interface {
+	Add(float64)
+}
+
+// CounterMetrics is a Metrics backed by counters such as prometheus.Counter.
+// Counters left nil are not updated.
+//
+//	slogcloud.WithMetrics(&slogcloud.CounterMetrics{
+//		Emitted: promauto.NewCounter(prometheus.CounterOpts{Name: "slogcloud_logs_emitted_total"}),
+//		Failed:  promauto.NewCounter(prometheus.CounterOpts{Name: "slogcloud_logs_failed_total"}),
+//	})
+type CounterMetrics struct {
+	Emitted            Counter
+	DroppedSampled     Counter
+	DroppedOverflow    Counter
+	DroppedCircuitOpen Counter
+	DroppedDiskBuffer  Counter
+	Failed             Counter
+	Batches            Counter
+	BytesSent          Counter
+	Retries            Counter
+	// CircuitOpened counts the times the circuit breaker opened.
+	CircuitOpened Counter
+}
+
+// LogEmitted increments Emitted.
+func (m *CounterMetrics) LogEmitted() {
+	add(m.Emitted, 1)
+}
+
+// LogDropped increments DroppedSampled, DroppedOverflow, DroppedCircuitOpen
+// or DroppedDiskBuffer depending on reason.
+func (m *CounterMetrics) LogDropped(reason DropReason) {
+	switch reason {
+	case DropSampled:
+		add(m.DroppedSampled, 1)
+	case DropQueueFull:
+		add(m.DroppedOverflow, 1)
+	case DropCircuitOpen:
+		add(m.DroppedCircuitOpen, 1)
+	case DropDiskBufferFull:
+		add(m.DroppedDiskBuffer, 1)
+	}
+}
+
+// LogsFailed adds n to Failed.
+func (m *CounterMetrics) LogsFailed(n int) {
+	add(m.Failed, float64(n))
+}
+
+// BatchSent increments Batches and adds bytes to BytesSent.
+func (m *CounterMetrics) BatchSent(events, bytes int) {
+	add(m.Batches, 1)
+	add(m.BytesSent, float64(bytes))
+}
+
+// Retry increments Retries.
+func (m *CounterMetrics) Retry() {
+	add(m.Retries, 1)
+}
+
+// CircuitStateChanged increments CircuitOpened when the circuit opens.
+func (m *CounterMetrics) CircuitStateChanged(state CircuitState) {
+	if state == CircuitOpen {
+		add(m.CircuitOpened, 1)
+	}
+}
+
+// add adds v to c unless c is nil.
+func add(c Counter, v float64) {
+	if c != nil {
+		c.Add(v)
+	}
+}
+
+// dropRecorder is implemented by sinks that count the logs dropped before
+// they reach them, such as by the handler's Sampler.
+type dropRecorder interface {
+	recordDrop(reason DropReason)
+}
+
+// recordDrop counts a log dropped before it reached the client.
+func (cw *CloudwatchClient) recordDrop(reason DropReason) {
+	cw.opts.metrics.LogDropped(reason)
+}
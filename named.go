@@ -0,0 +1,75 @@
+package slogcloud
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NameKey is the attribute key that holds the name of a logger created with WithName.
+const NameKey = "logger"
+
+// WithName returns a logger for a component, such as "auth" or "db", that
+// adds its name under NameKey to every log so logs can be filtered by
+// component. Names of nested loggers are joined with dots, so
+// WithName("auth").WithName("token") logs "auth.token". The returned logger
+// shares the handler, OnFatal hooks and exit function of s.
+func (s *SlogLogger) WithName(name string) *SlogLogger {
+	if name == "" {
+		return s
+	}
+	if s.name != "" {
+		name = s.name + "." + name
+	}
+
+	return &SlogLogger{
+		fatalExit: s.fatalExit,
+		handler:   s.handler,
+		logger:    s.unnamed.With(NameKey, name),
+		unnamed:   s.unnamed,
+		name:      name,
+	}
+}
+
+// WithLevel returns a logger that drops logs below level in addition to the
+// handler's own level, for example to make one component quieter than the
+// rest. Loggers derived from it with WithName keep the filter.
+func (s *SlogLogger) WithLevel(level slog.Leveler) *SlogLogger {
+	unnamed := slog.New(&levelHandler{Handler: s.unnamed.Handler(), level: level})
+	logger := unnamed
+	if s.name != "" {
+		logger = unnamed.With(NameKey, s.name)
+	}
+
+	return &SlogLogger{
+		fatalExit: s.fatalExit,
+		handler:   s.handler,
+		logger:    logger,
+		unnamed:   unnamed,
+		name:      s.name,
+	}
+}
+
+// levelHandler drops records below level before they reach the wrapped handler.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level.Level() {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,219 @@
+package slogcloud
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// NopLogger implements the Logger interface by discarding every log, which
+// is convenient in tests. Fatal still runs the OnFatal hooks and the exit
+// function, so code that relies on Fatal stopping the program keeps working.
+type NopLogger struct {
+	fatalExit
+}
+
+// NewNopLogger creates a NopLogger.
+func NewNopLogger() *NopLogger {
+	return &NopLogger{}
+}
+
+// Debug does nothing.
+func (l *NopLogger) Debug(msg string, args ...any) {}
+
+// Info does nothing.
+func (l *NopLogger) Info(msg string, args ...any) {}
+
+// Warn does nothing.
+func (l *NopLogger) Warn(msg string, args ...any) {}
+
+// Error does nothing.
+func (l *NopLogger) Error(msg string, err error, args ...any) {}
+
+// Fatal runs the OnFatal hooks and calls the exit function with the exit
+// code, 1 unless changed with SetExitCode.
+func (l *NopLogger) Fatal(msg string, err error, args ...any) {
+	l.FatalCode(msg, err, l.exitCode(), args...)
+}
+
+// FatalCode runs the OnFatal hooks and calls the exit function with code.
+func (l *NopLogger) FatalCode(msg string, err error, code int, args ...any) {
+	l.runHooks()
+	l.exitWith(code)
+}
+
+// DebugContext does nothing.
+func (l *NopLogger) DebugContext(_ context.Context, msg string, args ...any) {}
+
+// InfoContext does nothing.
+func (l *NopLogger) InfoContext(_ context.Context, msg string, args ...any) {}
+
+// WarnContext does nothing.
+func (l *NopLogger) WarnContext(_ context.Context, msg string, args ...any) {}
+
+// ErrorContext does nothing.
+func (l *NopLogger) ErrorContext(_ context.Context, msg string, err error, args ...any) {}
+
+// FatalContext is like Fatal. The context is ignored.
+func (l *NopLogger) FatalContext(_ context.Context, msg string, err error, args ...any) {
+	l.Fatal(msg, err, args...)
+}
+
+// With returns l, as there is nothing to add the arguments to.
+func (l *NopLogger) With(args ...any) Logger {
+	return l
+}
+
+// WithFields returns l, as there is nothing to add the fields to.
+func (l *NopLogger) WithFields(fields Fields) Logger {
+	return l
+}
+
+// Close does nothing.
+func (l *NopLogger) Close() error {
+	return nil
+}
+
+// CapturedLog is a log recorded by a CaptureLogger.
+type CapturedLog struct {
+	Level   slog.Level
+	Message string
+	// Err is the error passed to Error or Fatal.
+	Err error
+	// Args are the key-value pairs passed with the message.
+	Args []any
+}
+
+// CaptureLogger implements the Logger interface by recording every log in
+// memory so tests can assert on the messages and levels that were emitted.
+// Like NopLogger, Fatal runs the OnFatal hooks and the exit function after
+// recording the log. It is safe for concurrent use.
+type CaptureLogger struct {
+	// fatalExit and captured are shared with the loggers derived by With
+	*fatalExit
+	*captured
+
+	// args are the arguments added by With, recorded before each log's own
+	args []any
+}
+
+// captured holds the logs recorded by a CaptureLogger.
+type captured struct {
+	logsMu sync.Mutex
+	logs   []CapturedLog
+}
+
+// NewCaptureLogger creates an empty CaptureLogger.
+func NewCaptureLogger() *CaptureLogger {
+	return &CaptureLogger{fatalExit: &fatalExit{}, captured: &captured{}}
+}
+
+// Logs returns a copy of the logs recorded so far, in the order they were
+// emitted, including those of the loggers derived from it by With.
+func (l *CaptureLogger) Logs() []CapturedLog {
+	l.logsMu.Lock()
+	defer l.logsMu.Unlock()
+	logs := make([]CapturedLog, len(l.logs))
+	copy(logs, l.logs)
+	return logs
+}
+
+// Reset discards the recorded logs.
+func (l *CaptureLogger) Reset() {
+	l.logsMu.Lock()
+	defer l.logsMu.Unlock()
+	l.logs = nil
+}
+
+func (l *CaptureLogger) record(log CapturedLog) {
+	if len(l.args) > 0 {
+		log.Args = append(l.args[:len(l.args):len(l.args)], log.Args...)
+	}
+
+	l.logsMu.Lock()
+	defer l.logsMu.Unlock()
+	l.logs = append(l.logs, log)
+}
+
+// With returns a logger that records args before the arguments of every
+// log. Its logs are recorded with those of l.
+func (l *CaptureLogger) With(args ...any) Logger {
+	if len(args) == 0 {
+		return l
+	}
+	return &CaptureLogger{
+		fatalExit: l.fatalExit,
+		captured:  l.captured,
+		args:      append(l.args[:len(l.args):len(l.args)], args...),
+	}
+}
+
+// WithFields returns a logger that records fields, as a single argument,
+// before the arguments of every log.
+func (l *CaptureLogger) WithFields(fields Fields) Logger {
+	return l.With(fields)
+}
+
+// Debug records a debug log.
+func (l *CaptureLogger) Debug(msg string, args ...any) {
+	l.record(CapturedLog{Level: slog.LevelDebug, Message: msg, Args: args})
+}
+
+// Info records an info log.
+func (l *CaptureLogger) Info(msg string, args ...any) {
+	l.record(CapturedLog{Level: slog.LevelInfo, Message: msg, Args: args})
+}
+
+// Warn records a warning log.
+func (l *CaptureLogger) Warn(msg string, args ...any) {
+	l.record(CapturedLog{Level: slog.LevelWarn, Message: msg, Args: args})
+}
+
+// Error records an error log.
+func (l *CaptureLogger) Error(msg string, err error, args ...any) {
+	l.record(CapturedLog{Level: slog.LevelError, Message: msg, Err: err, Args: args})
+}
+
+// Fatal records a fatal log, runs the OnFatal hooks and calls the exit
+// function with the exit code, 1 unless changed with SetExitCode.
+func (l *CaptureLogger) Fatal(msg string, err error, args ...any) {
+	l.FatalCode(msg, err, l.exitCode(), args...)
+}
+
+// FatalCode records a fatal log, runs the OnFatal hooks and calls the exit
+// function with code.
+func (l *CaptureLogger) FatalCode(msg string, err error, code int, args ...any) {
+	l.record(CapturedLog{Level: LevelFatal, Message: msg, Err: err, Args: args})
+	l.runHooks()
+	l.exitWith(code)
+}
+
+// DebugContext records a debug log. The context is ignored.
+func (l *CaptureLogger) DebugContext(_ context.Context, msg string, args ...any) {
+	l.Debug(msg, args...)
+}
+
+// InfoContext records an info log. The context is ignored.
+func (l *CaptureLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+// WarnContext records a warning log. The context is ignored.
+func (l *CaptureLogger) WarnContext(_ context.Context, msg string, args ...any) {
+	l.Warn(msg, args...)
+}
+
+// ErrorContext records an error log. The context is ignored.
+func (l *CaptureLogger) ErrorContext(_ context.Context, msg string, err error, args ...any) {
+	l.Error(msg, err, args...)
+}
+
+// FatalContext is like Fatal. The context is ignored.
+func (l *CaptureLogger) FatalContext(_ context.Context, msg string, err error, args ...any) {
+	l.Fatal(msg, err, args...)
+}
+
+// Close does nothing.
+func (l *CaptureLogger) Close() error {
+	return nil
+}
@@ -0,0 +1,49 @@
+package slogcloud
+
+import "log/slog"
+
+// errorQueueSize is the number of failures that can wait for the OnError
+// callback before further failures are discarded.
+const errorQueueSize = 1000
+
+// failedRecord is a record that could not be logged and the reason why.
+type failedRecord struct {
+	err    error
+	record slog.Record
+}
+
+// reportError hands a failure to the OnError callback without blocking the
+// caller. The failure is discarded if the callback has fallen behind.
+func (cw *CloudwatchClient) reportError(err error, r slog.Record) {
+	if cw.opts.onError == nil {
+		return
+	}
+	select {
+	case cw.errs <- failedRecord{err: err, record: r}:
+	default:
+		cw.opts.debugf("OnError callback is falling behind, discarding failure: %v", err)
+	}
+}
+
+// reportErrors is the background goroutine that calls the OnError callback.
+// It stops once the client has stopped and every pending failure, including
+// those from the final flush, has been reported.
+func (cw *CloudwatchClient) reportErrors() {
+	defer close(cw.errorsDone)
+
+	for {
+		select {
+		case f := <-cw.errs:
+			cw.opts.onError(f.err, f.record)
+		case <-cw.stopped:
+			for {
+				select {
+				case f := <-cw.errs:
+					cw.opts.onError(f.err, f.record)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,728 @@
+package slogcloud
+
+import (
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// Option configures a CloudwatchClient.
+type Option func(*options)
+
+// options holds the configurable settings of a CloudwatchClient.
+type options struct {
+	region         string
+	endpoint       string
+	httpClient     aws.HTTPClient
+	credentials    aws.CredentialsProvider
+	profile        string
+	assumeRole     *assumeRole
+	batchSize      int
+	flushInterval  time.Duration
+	queueSize      int
+	overflowPolicy OverflowPolicy
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryMaxDelay  time.Duration
+	debugf         func(format string, args ...any)
+	onError        func(err error, r slog.Record)
+	fallback       io.Writer
+	metrics        Metrics
+	clock          Clock
+	retentionDays  *int32
+	tags           map[string]string
+	kmsKey         string
+	levelKey       string
+	levelValue     func(slog.Level) any
+	addSource      bool
+	seqNumbers     bool
+	formatter      Formatter
+	defaultAttrs   []slog.Attr
+	errorFormat    errorFormat
+	redaction      redaction
+	createLogGroup bool
+	logGroupWait   time.Duration
+	logStream      string
+	streamCount    int
+	errorStream    string
+	router         Router
+	maxRoutes      int
+	groupLevels    map[string]slog.Leveler
+	startupPing    bool
+
+	maxMessageBytes int
+	oversizePolicy  OversizePolicy
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	queueWarnPercent int
+
+	streamAttempts    int
+	streamRetryDelay  time.Duration
+	streamRetryJitter float64
+
+	diskDir      string
+	diskMaxBytes int64
+
+	hostname         bool
+	hostnameFallback string
+
+	dailyRotation    bool
+	rotationLocation *time.Location
+}
+
+func defaultOptions() options {
+	return options{
+		batchSize:      DefaultBatchSize,
+		flushInterval:  DefaultFlushInterval,
+		queueSize:      DefaultQueueSize,
+		overflowPolicy: OverflowBlock,
+		maxRetries:     DefaultMaxRetries,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		retryMaxDelay:  DefaultRetryMaxDelay,
+		debugf:         func(string, ...any) {},
+		metrics:        NopMetrics{},
+		clock:          SystemClock{},
+		levelKey:       DefaultLevelKey,
+		createLogGroup: true,
+		logGroupWait:   DefaultLogGroupWait,
+		streamCount:    1,
+		maxRoutes:      DefaultMaxRoutes,
+
+		streamAttempts:    DefaultStreamAttempts,
+		streamRetryDelay:  DefaultStreamRetryDelay,
+		streamRetryJitter: DefaultStreamRetryJitter,
+
+		rotationLocation: time.UTC,
+	}
+}
+
+// WithRegion sets the AWS region of the CloudWatch Logs endpoint. Without it
+// the region is taken from AWS_REGION, AWS_DEFAULT_REGION, the shared config
+// or, on EC2, the instance metadata.
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithEndpoint sends requests to the CloudWatch Logs endpoint at url, such as
+// LocalStack (http://localhost:4566) or a VPC interface endpoint, instead of
+// the default endpoint of the region. A region is still required, as requests
+// are signed for it.
+func WithEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoint = url
+	}
+}
+
+// WithHTTPClient sends requests through client, typically an *http.Client
+// tuned for logging, for example with a short overall timeout so a slow
+// endpoint cannot stall the flusher, or more idle connections for high
+// throughput. By default the AWS SDK's HTTP client is used, which limits how
+// long connecting and TLS handshakes may take but sets no overall timeout on
+// a request; each PutLogEvents call is then bounded only by the retry policy
+// and the context passed to Flush.
+func WithHTTPClient(client aws.HTTPClient) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithStaticCredentials authenticates with a fixed access key pair instead of
+// the default AWS credential chain.
+func WithStaticCredentials(accessKey, secretAccessKey string) Option {
+	return func(o *options) {
+		o.credentials = credentials.NewStaticCredentialsProvider(accessKey, secretAccessKey, "")
+	}
+}
+
+// WithSharedConfigProfile loads credentials and settings from the named
+// profile of the shared AWS config and credentials files, such as a profile
+// signed in with "aws sso login", instead of the default profile. The
+// profile's region is used unless WithRegion is given, and its credentials
+// unless WithStaticCredentials is given. It has no effect on
+// NewCloudwatchClientFromConfig, whose config is already loaded.
+func WithSharedConfigProfile(name string) Option {
+	return func(o *options) {
+		o.profile = name
+	}
+}
+
+// assumeRole describes the IAM role the client assumes before writing logs.
+type assumeRole struct {
+	roleARN     string
+	sessionName string
+	externalID  string
+}
+
+// WithAssumeRole makes the client assume roleARN, for example a role in a
+// central logging account, and write to the log group in that role's account.
+// The role is assumed with the credentials the client would otherwise use,
+// from WithStaticCredentials, WithSharedConfigProfile or the default AWS
+// credential chain, and refreshed before it expires.
+func WithAssumeRole(roleARN, sessionName string) Option {
+	return func(o *options) {
+		if o.assumeRole == nil {
+			o.assumeRole = &assumeRole{}
+		}
+		o.assumeRole.roleARN = roleARN
+		o.assumeRole.sessionName = sessionName
+	}
+}
+
+// WithExternalID sets the external ID passed when assuming the role given to
+// WithAssumeRole, for roles whose trust policy requires one.
+func WithExternalID(externalID string) Option {
+	return func(o *options) {
+		if o.assumeRole == nil {
+			o.assumeRole = &assumeRole{}
+		}
+		o.assumeRole.externalID = externalID
+	}
+}
+
+// WithBatchSize sets the number of buffered events that triggers a flush.
+// Values are capped at CloudWatch's limit of 10,000 events per call.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		if n < 1 {
+			n = 1
+		}
+		if n > maxBatchEvents {
+			n = maxBatchEvents
+		}
+		o.batchSize = n
+	}
+}
+
+// WithFlushInterval sets how long events may sit in the buffer before they
+// are flushed, regardless of the batch size.
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.flushInterval = d
+		}
+	}
+}
+
+// WithQueueSize sets how many log events can wait to be batched before the
+// overflow policy kicks in. A larger queue absorbs longer bursts and CloudWatch
+// slowdowns without blocking or dropping, at the cost of holding up to that
+// many formatted events in memory.
+func WithQueueSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.queueSize = n
+		}
+	}
+}
+
+// WithQueueWarning warns through the WithDebugf function when the queue
+// reaches percent of its size, so the queue and batch sizes can be raised
+// before logs start to block or be dropped. The warning is repeated only
+// after the queue has drained below the threshold again.
+func WithQueueWarning(percent int) Option {
+	return func(o *options) {
+		if percent > 0 && percent <= 100 {
+			o.queueWarnPercent = percent
+		}
+	}
+}
+
+// WithOverflowPolicy sets what happens when a log is emitted while the queue
+// is full. OverflowBlock never loses logs but adds CloudWatch latency to the
+// caller once the queue is full; the drop policies keep logging non-blocking
+// at the cost of losing events.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflowPolicy = p
+	}
+}
+
+// WithMaxRetries sets how many times a PutLogEvents call failing with a
+// retryable error is retried. Zero disables retries. These are the only
+// retries of PutLogEvents: the SDK's retryer, including one set in the
+// aws.Config given to NewCloudwatchClientFromConfig, is not used for it.
+func WithMaxRetries(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.maxRetries = n
+		}
+	}
+}
+
+// WithRetryDelay sets the exponential backoff used between PutLogEvents
+// retries. The delay starts at base, doubles on every attempt up to maxDelay, and
+// is randomized to avoid many clients retrying in lockstep. A maxDelay below
+// base is raised to base.
+func WithRetryDelay(base, maxDelay time.Duration) Option {
+	return func(o *options) {
+		if base > 0 {
+			o.retryBaseDelay = base
+		}
+		if maxDelay > 0 {
+			o.retryMaxDelay = maxDelay
+		}
+		o.retryMaxDelay = max(o.retryMaxDelay, o.retryBaseDelay)
+	}
+}
+
+// WithStreamRetry sets how creating the client's log streams is retried when
+// CloudWatch fails, for example under throttling while many replicas start at
+// once: up to attempts tries in total, waiting delay between them, moved
+// randomly by up to jitter times delay either way so the replicas spread out
+// their retries. The defaults are DefaultStreamAttempts, DefaultStreamRetryDelay
+// and DefaultStreamRetryJitter.
+func WithStreamRetry(attempts int, delay time.Duration, jitter float64) Option {
+	return func(o *options) {
+		if attempts > 0 {
+			o.streamAttempts = attempts
+		}
+		if delay >= 0 {
+			o.streamRetryDelay = delay
+		}
+		if jitter >= 0 && jitter <= 1 {
+			o.streamRetryJitter = jitter
+		}
+	}
+}
+
+// WithDebugf sets the function that receives the client's own diagnostic
+// messages, such as log group setup and failed flushes. By default they are
+// discarded so the library never writes to the host application's output.
+// A logger of its own is a convenient choice while debugging:
+//
+//	slogcloud.WithDebugf(log.New(os.Stderr, "slogcloud: ", log.LstdFlags).Printf)
+//
+// log.Printf works too: while RedirectStdLog sends the standard logger to a
+// client, the diagnostics still go to the logger's previous output.
+func WithDebugf(debugf func(format string, args ...any)) Option {
+	return func(o *options) {
+		if debugf == nil {
+			return
+		}
+		if isStdLogPrintf(debugf) {
+			debugf = stdLogDebugf
+		}
+		o.debugf = debugf
+	}
+}
+
+// WithOnError sets a callback that receives every record that could not be
+// logged, together with the reason: a full queue, a closed client, a
+// cancelled context or a failed PutLogEvents call. Use it to count dropped
+// logs or write them somewhere else.
+//
+// The callback is called from a background goroutine, one failure at a time,
+// so it may log through the same client without deadlocking. Failures that
+// arrive while the callback is still busy with earlier ones are discarded
+// once 1000 of them are pending.
+func WithOnError(fn func(err error, r slog.Record)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// WithFallbackWriter writes events that could not be sent to CloudWatch, once
+// retries are exhausted, to w as a last resort so they are not lost, for
+// example when AWS is unreachable or the credentials have expired. Each event
+// is written as one line of the same JSON that would have been sent.
+// os.Stderr is the usual choice. FallbackWrites reports how many events were
+// written this way.
+func WithFallbackWriter(w io.Writer) Option {
+	return func(o *options) {
+		o.fallback = w
+	}
+}
+
+// WithDiskBuffer keeps log events that could not be sent, for example during
+// an AWS outage or with the circuit breaker open, in files in dir instead of
+// losing them, and replays them once sending to CloudWatch works again,
+// oldest first. Files left by a previous run, such as one that was shut down
+// during an outage, are replayed too. Once the files exceed maxBytes the
+// oldest logs are dropped. Events are delivered at least once: a crash while
+// replaying can send some of them twice. Each client needs a directory of its
+// own.
+func WithDiskBuffer(dir string, maxBytes int64) Option {
+	return func(o *options) {
+		o.diskDir = dir
+		o.diskMaxBytes = maxBytes
+	}
+}
+
+// WithSource adds where each log was emitted under "source", as an object
+// with its function, file and line, like slog's AddSource option. It costs a
+// stack frame lookup per log, so it is off by default.
+func WithSource(enabled bool) Option {
+	return func(o *options) {
+		o.addSource = enabled
+	}
+}
+
+// WithSequenceNumbers adds a sequence number to every log under SeqKey,
+// counting from 1 for each client, so a gap in the numbers found in
+// CloudWatch shows that logs were lost on the way. Numbers are shared by all
+// streams and routes of the client; logs filtered out by level never get one.
+func WithSequenceNumbers(enabled bool) Option {
+	return func(o *options) {
+		o.seqNumbers = enabled
+	}
+}
+
+// WithFormatter encodes every log with f instead of the default format, for
+// example ECSFormatter for the Elastic Common Schema. WithLevelKey and
+// WithLevelValue only apply to the default format.
+func WithFormatter(f Formatter) Option {
+	return func(o *options) {
+		o.formatter = f
+	}
+}
+
+// WithCircuitBreaker stops calling CloudWatch after threshold consecutive
+// batches failed to send, so an outage or sustained throttling does not keep
+// the client retrying. For cooldown the batches are written to the fallback
+// writer, if there is one, or dropped, and reported to the OnError callback
+// with ErrCircuitOpen. After the cooldown a single batch probes CloudWatch,
+// closing the circuit if it is sent and opening it again if it fails. State
+// changes are reported to Metrics.CircuitStateChanged.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.breakerThreshold = threshold
+		o.breakerCooldown = cooldown
+	}
+}
+
+// WithClock reads the current time from c instead of the system clock. It is
+// used for the timestamps of events without one, to clamp timestamps to the
+// range CloudWatch accepts and to name generated log streams. Waits and retry
+// backoffs always use the system clock.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		if c != nil {
+			o.clock = c
+		}
+	}
+}
+
+// WithMetrics reports counts of emitted, dropped and failed logs, batches,
+// bytes sent and retries to m. Use CounterMetrics to back them with
+// Prometheus counters.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		if m != nil {
+			o.metrics = m
+		}
+	}
+}
+
+// WithRetention sets how many days CloudWatch keeps the log group's events.
+// It must be one of the values CloudWatch accepts (1, 3, 5, 7, 14, 30, 60, 90,
+// 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288 or
+// 3653), or 0 to never expire. Without this option the retention of an
+// existing group is left untouched.
+func WithRetention(days int32) Option {
+	return func(o *options) {
+		o.retentionDays = &days
+	}
+}
+
+// WithTags applies tags, such as cost-allocation tags, to the log group. They
+// are set when the group is created and added to the group when it already
+// exists, which requires the logs:TagLogGroup permission. At most 50 tags are
+// allowed, with keys of 1 to 128 characters and values of up to 256.
+func WithTags(tags map[string]string) Option {
+	return func(o *options) {
+		o.tags = maps.Clone(tags)
+	}
+}
+
+// WithLevelKey sets the JSON key the level of each log is stored under.
+// The default is "level".
+func WithLevelKey(key string) Option {
+	return func(o *options) {
+		if key != "" {
+			o.levelKey = key
+		}
+	}
+}
+
+// WithDefaultAttrs adds attrs, such as the service name, version and hostname,
+// to every log the client sends, whichever logger or handler it came from.
+// Attributes of a log with the same key take precedence.
+func WithDefaultAttrs(attrs ...slog.Attr) Option {
+	return func(o *options) {
+		o.defaultAttrs = append(o.defaultAttrs, attrs...)
+	}
+}
+
+// WithAttrsFromEnv adds default attributes read from environment variables,
+// such as the POD_NAME and NAMESPACE of a Kubernetes pod. attrs maps each
+// attribute key to the name of the variable holding its value; variables that
+// are unset or empty are skipped. The variables are read once, when the
+// client is created, so later changes are not picked up.
+//
+//	slogcloud.WithAttrsFromEnv(map[string]string{"pod": "POD_NAME", "node": "NODE_NAME"})
+func WithAttrsFromEnv(attrs map[string]string) Option {
+	return func(o *options) {
+		for _, key := range slices.Sorted(maps.Keys(attrs)) {
+			if value := os.Getenv(attrs[key]); value != "" {
+				o.defaultAttrs = append(o.defaultAttrs, slog.String(key, value))
+			}
+		}
+	}
+}
+
+// WithHostname adds the hostname of the machine, as returned by os.Hostname,
+// to every log under "host". It is read once, when the client is created. If
+// it cannot be read, the value given with WithHostnameFallback is used, or the
+// attribute is left out and a warning is written to the WithDebugf function.
+// A "host" attribute given with WithDefaultAttrs or on a log takes precedence.
+func WithHostname(enabled bool) Option {
+	return func(o *options) {
+		o.hostname = enabled
+	}
+}
+
+// WithHostnameFallback sets the value WithHostname adds when the hostname
+// cannot be read.
+func WithHostnameFallback(value string) Option {
+	return func(o *options) {
+		o.hostnameFallback = value
+	}
+}
+
+// hostnameAttrs returns the default attributes of o preceded by the hostname,
+// so default attributes with the same key win.
+func hostnameAttrs(o options) []slog.Attr {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		if o.hostnameFallback == "" {
+			o.debugf("Could not read the hostname, leaving out the %s attribute: %v", HostKey, err)
+			return o.defaultAttrs
+		}
+		host = o.hostnameFallback
+	}
+	return slices.Concat([]slog.Attr{slog.String(HostKey, host)}, o.defaultAttrs)
+}
+
+// WithErrorDetails serializes error attributes as an object holding the
+// message, the concrete type and the chain of wrapped errors, instead of only
+// the message:
+//
+//	"error": {"message": "load config: not found", "type": "*fmt.wrapError",
+//	          "chain": [{"message": "not found", "type": "*errors.errorString"}]}
+func WithErrorDetails() Option {
+	return func(o *options) {
+		o.errorFormat.details = true
+	}
+}
+
+// WithErrorStackTraces adds the stack trace of errors that carry one, that is
+// errors with a StackTrace method such as those of github.com/pkg/errors,
+// under "stack" in the serialized error object.
+func WithErrorStackTraces() Option {
+	return func(o *options) {
+		o.errorFormat.stack = true
+	}
+}
+
+// WithRedactedKeys replaces the values of attributes with one of keys by
+// "[REDACTED]" before logs are sent, so sensitive fields never reach
+// CloudWatch. Keys are matched ignoring case, at any depth of nested groups,
+// and a matching group redacts all of its members; a dotted key such as
+// "user.password" only matches within those groups.
+func WithRedactedKeys(keys ...string) Option {
+	return func(o *options) {
+		if o.redaction.keys == nil {
+			o.redaction.keys = make(map[string]struct{}, len(keys))
+		}
+		for _, key := range keys {
+			o.redaction.keys[strings.ToLower(key)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactedPattern replaces every match of re in log messages and string
+// attribute values by "[REDACTED]" before logs are sent, for example to mask
+// credit card numbers wherever they appear.
+func WithRedactedPattern(re *regexp.Regexp) Option {
+	return func(o *options) {
+		if re != nil {
+			o.redaction.patterns = append(o.redaction.patterns, re)
+		}
+	}
+}
+
+// WithLevelValue sets the value stored under the level key, for metric and
+// subscription filters that expect a particular shape, for example
+// SyslogSeverity for numeric syslog severities. By default the level's name,
+// such as "ERROR", is stored. Combine it with WithLevelKey to rename the key:
+//
+//	slogcloud.WithLevelKey("severity"), slogcloud.WithLevelValue(slogcloud.SyslogSeverity)
+func WithLevelValue(fn func(slog.Level) any) Option {
+	return func(o *options) {
+		o.levelValue = fn
+	}
+}
+
+// WithCreateLogGroup controls whether the client checks for the log group and
+// creates it when missing. Disable it when the IAM role may create log streams
+// but not log groups, or not call logs:DescribeLogGroups; the group is then
+// assumed to exist without any call, and a missing group is reported as a
+// ResourceNotFoundException when the log stream is created or events are
+// sent. WithTags, WithKMSKey and WithRetention are ignored then, since
+// applying them needs the permissions this option avoids.
+func WithCreateLogGroup(create bool) Option {
+	return func(o *options) {
+		o.createLogGroup = create
+	}
+}
+
+// WithKMSKey encrypts the log group with the customer-managed KMS key
+// keyARN. New log groups are created with the key, and existing groups that
+// use another key, or none, are associated with it. The key policy must allow
+// the CloudWatch Logs service principal of the region to use the key, and
+// associating it with an existing group requires logs:AssociateKmsKey.
+func WithKMSKey(keyARN string) Option {
+	return func(o *options) {
+		o.kmsKey = keyARN
+	}
+}
+
+// WithLogGroupWait sets how long the client waits for a log group it created
+// to become visible before creating its log stream. The group is usually
+// visible right away, in which case the client does not wait at all. Pass 0
+// to never wait, for example to speed up cold starts.
+func WithLogGroupWait(d time.Duration) Option {
+	return func(o *options) {
+		if d >= 0 {
+			o.logGroupWait = d
+		}
+	}
+}
+
+// WithLogStream sets the name of the log stream to write to, for example the
+// hostname or pod name, instead of a generated unique name. An existing stream
+// with that name is reused. The name must not contain ':' or '*'.
+func WithLogStream(name string) Option {
+	return func(o *options) {
+		o.logStream = name
+	}
+}
+
+// WithDailyRotation makes the client switch to a new log stream every day at
+// midnight UTC, or in the location given with WithRotationLocation. Streams
+// are named after the WithLogStream name, or DefaultRotationPrefix, and the
+// date, such as "app-2024-06-01". The first log of the new day, by its
+// timestamp, starts creating the new stream in the background; logs keep going
+// to the old stream until it exists, and are then sent before the switch.
+// Replicas rotating at the same time share the stream, each appending to it
+// once another has created it.
+func WithDailyRotation(enabled bool) Option {
+	return func(o *options) {
+		o.dailyRotation = enabled
+	}
+}
+
+// WithRotationLocation sets the time zone whose midnight WithDailyRotation
+// switches streams at, and whose date names them. The default is time.UTC; a
+// nil loc is ignored.
+func WithRotationLocation(loc *time.Location) Option {
+	return func(o *options) {
+		if loc != nil {
+			o.rotationLocation = loc
+		}
+	}
+}
+
+// WithMaxMessageBytes shrinks logs whose JSON exceeds n bytes using policy,
+// for example to avoid paying to ingest large blobs. Logs larger than the 256
+// KB CloudWatch accepts are shrunk with policy too, whatever n is. Without
+// this option logs are sent as they are, and those too large for CloudWatch
+// are rejected with ErrMessageTooLarge.
+func WithMaxMessageBytes(n int, policy OversizePolicy) Option {
+	return func(o *options) {
+		o.maxMessageBytes = n
+		o.oversizePolicy = policy
+	}
+}
+
+// WithStreamCount spreads events round-robin across n log streams, created
+// when the client starts, to raise the ingestion throughput beyond what a
+// single stream allows. Streams are named after the log stream name with a
+// "-1" to "-n" suffix. Events within a stream stay in chronological order;
+// ordering across streams is best effort.
+func WithStreamCount(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.streamCount = n
+		}
+	}
+}
+
+// WithStartupPing makes creating the client send an info log, "slogcloud
+// initialized", with the log group, stream and region, and wait until
+// CloudWatch accepts it. Misconfigured credentials, permissions or endpoints
+// then make the constructor fail instead of surfacing with the first real log.
+func WithStartupPing(enabled bool) Option {
+	return func(o *options) {
+		o.startupPing = enabled
+	}
+}
+
+// WithErrorStream sends records at slog.LevelError and above to the log
+// stream name in the client's log group, created when the client starts,
+// instead of the client's own streams. A metric filter on that stream can
+// then alert on errors without scanning every log.
+func WithErrorStream(name string) Option {
+	return func(o *options) {
+		o.errorStream = name
+	}
+}
+
+// WithRouter sends each record to the log group and stream chosen by router,
+// for example a log group per tenant. Log groups and streams are created the
+// first time they are used, with the same tags and retention as the client's
+// log group. Records the router sends to the client's own group and stream are
+// spread across its streams as usual.
+func WithRouter(router Router) Option {
+	return func(o *options) {
+		o.router = router
+	}
+}
+
+// WithMinLevelPerGroup sets a minimum level for the records sent to each of
+// the given log groups, such as everything for an audit group but only
+// warnings and above for the client's own group. Records below the level of
+// the group the router picks for them are dropped; groups without a level
+// accept every record. These levels only filter further: a record must first
+// pass the level of the handler or logger that emits it.
+func WithMinLevelPerGroup(levels map[string]slog.Leveler) Option {
+	return func(o *options) {
+		if o.groupLevels == nil {
+			o.groupLevels = make(map[string]slog.Leveler, len(levels))
+		}
+		maps.Copy(o.groupLevels, levels)
+	}
+}
+
+// WithMaxRoutes caps the number of routed log streams kept open at n. Once the
+// cap is reached, the least recently used route is flushed and closed to make
+// room for a new one. The default is DefaultMaxRoutes.
+func WithMaxRoutes(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxRoutes = n
+		}
+	}
+}
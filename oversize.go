@@ -0,0 +1,173 @@
+package slogcloud
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
+)
+
+// OversizePolicy decides how a log whose JSON exceeds the configured maximum
+// size is shrunk before it is sent.
+type OversizePolicy int
+
+const (
+	// OversizeTruncate keeps only the message and level and adds "truncated":true.
+	// The message itself is shortened if it is still too large.
+	OversizeTruncate OversizePolicy = iota
+	// OversizeDropAttrs removes the largest attributes until the log fits and
+	// lists their keys under "dropped_attrs".
+	OversizeDropAttrs
+	// OversizeCompress keeps the message and level and stores the complete log,
+	// gzip-compressed and base64-encoded, under "compressed".
+	OversizeCompress
+)
+
+// formatRecord builds the JSON sent to CloudWatch for r, shrinking it
+// according to the oversize policy when it exceeds the maximum size.
+func (cw *CloudwatchClient) formatRecord(r slog.Record) []byte {
+	r = cw.opts.redaction.redact(cw.withSequence(cw.withSource(cw.withDefaultAttrs(r))))
+	if cw.opts.formatter != nil {
+		return cw.formatWith(cw.opts.formatter, r)
+	}
+	return cw.formatDefault(r)
+}
+
+// formatDefault builds the JSON of r in the default format.
+func (cw *CloudwatchClient) formatDefault(r slog.Record) []byte {
+	// Logs with only a message and a level, such as logger.Info("starting"),
+	// are common enough to skip building a map for
+	if r.NumAttrs() == 0 && cw.opts.levelKey != "message" {
+		data, err := marshalBare(r.Message, cw.opts.levelKey, cw.levelValue(r.Level))
+		if limit := cw.messageLimit(); err == nil && (limit <= 0 || len(data) <= limit) {
+			return data
+		}
+	}
+
+	entry := buildLogEntry(r, cw.opts.levelKey, cw.opts.errorFormat)
+	if cw.opts.levelValue != nil {
+		entry[cw.opts.levelKey] = cw.opts.levelValue(r.Level)
+	}
+	data, err := marshalEntry(entry)
+	if err != nil {
+		cw.opts.debugf("Log %q has attributes that cannot be encoded as JSON, sending them as text: %v", r.Message, err)
+	}
+
+	limit := cw.messageLimit()
+	if limit <= 0 || len(data) <= limit {
+		releaseLogEntry(entry)
+		return data
+	}
+
+	switch cw.opts.oversizePolicy {
+	case OversizeDropAttrs:
+		if shrunk, ok := cw.dropAttrs(entry, limit); ok {
+			return shrunk
+		}
+	case OversizeCompress:
+		if shrunk, ok := cw.compress(r, data, limit); ok {
+			return shrunk
+		}
+	}
+
+	return cw.truncate(r, limit)
+}
+
+// messageLimit returns the size logs are shrunk to, or zero if they are not
+// shrunk. A limit above what CloudWatch accepts is lowered to fit.
+func (cw *CloudwatchClient) messageLimit() int {
+	if cw.opts.maxMessageBytes <= 0 {
+		return 0
+	}
+	return min(cw.opts.maxMessageBytes, maxEventMessage)
+}
+
+// truncate keeps only the message and level of r, shortening the message if
+// needed to stay within limit.
+func (cw *CloudwatchClient) truncate(r slog.Record, limit int) []byte {
+	entry := map[string]interface{}{
+		"message":        r.Message,
+		cw.opts.levelKey: cw.levelValue(r.Level),
+		"truncated":      true,
+	}
+	data, _ := json.Marshal(entry)
+	if len(data) <= limit {
+		return data
+	}
+
+	// Escaping can make the message longer in JSON than in Go, so shorten by
+	// the overshoot and retry until it fits
+	message := r.Message
+	for len(data) > limit && message != "" {
+		message = truncateString(message, len(message)-(len(data)-limit))
+		entry["message"] = message
+		data, _ = json.Marshal(entry)
+	}
+	return data
+}
+
+// dropAttrs removes the largest attributes from entry until it fits in limit.
+func (cw *CloudwatchClient) dropAttrs(entry map[string]interface{}, limit int) ([]byte, bool) {
+	type sizedKey struct {
+		key  string
+		size int
+	}
+	var keys []sizedKey
+	for key, val := range entry {
+		if key == "message" || key == cw.opts.levelKey {
+			continue
+		}
+		encoded, _ := json.Marshal(val)
+		keys = append(keys, sizedKey{key: key, size: len(key) + len(encoded)})
+	}
+	slices.SortFunc(keys, func(a, b sizedKey) int {
+		return cmp.Compare(b.size, a.size)
+	})
+
+	var dropped []string
+	for _, k := range keys {
+		delete(entry, k.key)
+		dropped = append(dropped, k.key)
+		entry["dropped_attrs"] = dropped
+
+		data, _ := json.Marshal(entry)
+		if len(data) <= limit {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// compress stores the complete log gzip-compressed next to the message and level.
+func (cw *CloudwatchClient) compress(r slog.Record, data []byte, limit int) ([]byte, bool) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+
+	entry := map[string]interface{}{
+		"message":        r.Message,
+		cw.opts.levelKey: cw.levelValue(r.Level),
+		"compressed":     base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}
+	shrunk, _ := json.Marshal(entry)
+	return shrunk, len(shrunk) <= limit
+}
+
+// truncateString shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncateString(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
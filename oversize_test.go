@@ -0,0 +1,140 @@
+package slogcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHugeAttributeRejected(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+	cw, fake := newTestClient(t, WithOnError(func(err error, _ slog.Record) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}))
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "upload received", 0)
+	r.AddAttrs(slog.String("body", strings.Repeat("x", 300*1024)))
+	err := cw.EmitLog(r)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("EmitLog = %v, want %v", err, ErrMessageTooLarge)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "bytes exceed the limit") || !strings.Contains(msg, `"upload received"`) {
+		t.Errorf("error %q does not name the size and the log", msg)
+	}
+
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "next", 0)); err != nil {
+		t.Fatalf("EmitLog: %v", err)
+	}
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := fake.messages(); len(got) != 1 || !strings.Contains(got[0], `"next"`) {
+		t.Errorf("sent %.200q, want only the next log", got)
+	}
+
+	// The callback runs in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(reported)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 || !errors.Is(reported[0], ErrMessageTooLarge) {
+		t.Errorf("reported %v, want one ErrMessageTooLarge", reported)
+	}
+}
+
+func TestHugeAttributeShrunk(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy OversizePolicy
+		check  func(t *testing.T, entry map[string]any)
+	}{
+		{"truncate", OversizeTruncate, func(t *testing.T, entry map[string]any) {
+			want := map[string]any{"message": "upload received", "level": "INFO", "truncated": true}
+			if !reflect.DeepEqual(entry, want) {
+				t.Errorf("sent %v, want %v", entry, want)
+			}
+		}},
+		{"drop attrs", OversizeDropAttrs, func(t *testing.T, entry map[string]any) {
+			if entry["user"] != "bob" || !reflect.DeepEqual(entry["dropped_attrs"], []any{"body"}) {
+				t.Errorf("sent %.200v, want body dropped and user kept", entry)
+			}
+		}},
+		{"compress", OversizeCompress, func(t *testing.T, entry map[string]any) {
+			if _, ok := entry["compressed"].(string); !ok || entry["body"] != nil {
+				t.Errorf("sent %.200v, want the log compressed", entry)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cw, fake := newTestClient(t, WithMaxMessageBytes(64*1024, tt.policy))
+			r := slog.NewRecord(time.Now(), slog.LevelInfo, "upload received", 0)
+			r.AddAttrs(slog.String("body", strings.Repeat("x", 300*1024)), slog.String("user", "bob"))
+			if err := cw.EmitLog(r); err != nil {
+				t.Fatalf("EmitLog: %v", err)
+			}
+			if err := cw.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			messages := fake.messages()
+			if len(messages) != 1 || len(messages[0]) > 64*1024 {
+				t.Fatalf("sent %d logs, want 1 within the limit", len(messages))
+			}
+			tt.check(t, fake.entries(t)[0])
+		})
+	}
+}
+
+// truncationFailingFormatter formats records like JSONFormatter but fails on
+// the truncated ones.
+type truncationFailingFormatter struct{}
+
+func (truncationFailingFormatter) Format(r slog.Record, attrs map[string]any) ([]byte, error) {
+	if _, ok := attrs["truncated"]; ok {
+		return nil, errors.New("cannot format truncated log")
+	}
+	return JSONFormatter{}.Format(r, attrs)
+}
+
+func TestFormatterFailsOnTruncatedLog(t *testing.T) {
+	var debug []string
+	cw, _ := newTestClient(t,
+		WithFormatter(truncationFailingFormatter{}),
+		WithMaxMessageBytes(100, OversizeTruncate),
+		WithDebugf(func(format string, args ...any) { debug = append(debug, fmt.Sprintf(format, args...)) }),
+	)
+
+	data := cw.formatWith(truncationFailingFormatter{}, slog.NewRecord(time.Now(), slog.LevelInfo, strings.Repeat("x", 500), 0))
+	var entry map[string]any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("sent %q, want a truncated log in the default format: %v", data, err)
+	}
+	if len(data) > 100 || entry["truncated"] != true {
+		t.Errorf("sent %q, want a truncated log of at most 100 bytes", data)
+	}
+	if !slices.ContainsFunc(debug, func(s string) bool { return strings.Contains(s, "cannot format truncated log") }) {
+		t.Errorf("debug output %q does not report the Formatter's error", debug)
+	}
+}
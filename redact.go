@@ -0,0 +1,75 @@
+package slogcloud
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the values removed by WithRedactedKeys and WithRedactedPattern.
+const Redacted = "[REDACTED]"
+
+// redaction holds the keys and value patterns the client redacts.
+type redaction struct {
+	// keys are lower-cased keys or dotted group paths
+	keys     map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+func (rd *redaction) enabled() bool {
+	return len(rd.keys) > 0 || len(rd.patterns) > 0
+}
+
+// redact returns r with redacted attribute values and, for patterns, with
+// matches in the message and in string values replaced by Redacted.
+func (rd *redaction) redact(r slog.Record) slog.Record {
+	if !rd.enabled() {
+		return r
+	}
+
+	redacted := replaceRecordAttrs(r, func(groups []string, a slog.Attr) slog.Attr {
+		if rd.matchesKey(groups, a.Key) {
+			return slog.String(a.Key, Redacted)
+		}
+		if a.Value.Kind() == slog.KindString {
+			return slog.String(a.Key, rd.redactString(a.Value.String()))
+		}
+		return a
+	})
+	redacted.Message = rd.redactString(r.Message)
+	return redacted
+}
+
+// matchesKey reports whether key, nested in groups, is redacted: either the
+// key or one of its groups is a redacted key by itself, or the dotted path
+// leading to it is.
+func (rd *redaction) matchesKey(groups []string, key string) bool {
+	if len(rd.keys) == 0 {
+		return false
+	}
+
+	path := ""
+	for _, name := range append(groups[:len(groups):len(groups)], key) {
+		name = strings.ToLower(name)
+		if _, ok := rd.keys[name]; ok {
+			return true
+		}
+		if path == "" {
+			path = name
+		} else {
+			path += "." + name
+		}
+		if _, ok := rd.keys[path]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replaces every match of the redacted patterns in s.
+func (rd *redaction) redactString(s string) string {
+	for _, re := range rd.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package slogcloud
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedactedValuesNeverSent(t *testing.T) {
+	const secret = "hunter2"
+	card := regexp.MustCompile(`\b\d{4}-\d{4}-\d{4}-\d{4}\b`)
+	tests := []struct {
+		name   string
+		opts   []Option
+		log    func(l *slog.Logger)
+		secret string
+	}{
+		{
+			name: "key ignoring case",
+			opts: []Option{WithRedactedKeys("password")},
+			log:  func(l *slog.Logger) { l.Info("login", "Password", secret) },
+		},
+		{
+			name: "key in nested group",
+			opts: []Option{WithRedactedKeys("token")},
+			log: func(l *slog.Logger) {
+				l.WithGroup("req").Info("call", slog.Group("auth", "token", secret))
+			},
+		},
+		{
+			name: "matching group",
+			opts: []Option{WithRedactedKeys("credentials")},
+			log:  func(l *slog.Logger) { l.Info("call", slog.Group("credentials", "user", "bob", "key", secret)) },
+		},
+		{
+			name: "dotted key",
+			opts: []Option{WithRedactedKeys("user.password")},
+			log:  func(l *slog.Logger) { l.Info("signup", slog.Group("user", "password", secret)) },
+		},
+		{
+			name: "handler attributes",
+			opts: []Option{WithRedactedKeys("ssn")},
+			log:  func(l *slog.Logger) { l.With("ssn", secret).Info("lookup") },
+		},
+		{
+			name: "LogValuer",
+			opts: []Option{WithRedactedKeys("password")},
+			log:  func(l *slog.Logger) { l.Info("login", "password", lazyString(secret)) },
+		},
+		{
+			name:   "pattern in message and values",
+			opts:   []Option{WithRedactedPattern(card)},
+			log:    func(l *slog.Logger) { l.Info("paid with 4111-1111-1111-1111", "note", "card 4111-1111-1111-1111") },
+			secret: "4111",
+		},
+		{
+			name: "custom formatter",
+			opts: []Option{WithRedactedKeys("password"), WithFormatter(ECSFormatter{})},
+			log:  func(l *slog.Logger) { l.Info("login", "password", secret) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cw, fake := newTestClient(t, tt.opts...)
+			tt.log(slog.New(NewCloudWatchLogHandler(cw)))
+			if err := cw.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			leaked := cmp.Or(tt.secret, secret)
+			messages := fake.messages()
+			if len(messages) != 1 {
+				t.Fatalf("sent %d logs, want 1", len(messages))
+			}
+			if strings.Contains(messages[0], leaked) || !strings.Contains(messages[0], Redacted) {
+				t.Errorf("sent %s", messages[0])
+			}
+		})
+	}
+}
+
+// lazyString is a LogValuer that resolves to its own value.
+type lazyString string
+
+func (s lazyString) LogValue() slog.Value {
+	return slog.StringValue(string(s))
+}
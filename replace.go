@@ -0,0 +1,61 @@
+package slogcloud
+
+import "log/slog"
+
+// WithReplaceAttr rewrites every attribute before it is sent, as
+// slog.HandlerOptions.ReplaceAttr does for slog's built-in handlers, for
+// example to mask passwords or rename keys. groups lists the groups the
+// attribute is nested in. Returning an attribute with an empty key drops it.
+// replace is called for the attributes of the log only, not for the message
+// and level, and not for group attributes themselves but for their members.
+func WithReplaceAttr(replace func(groups []string, a slog.Attr) slog.Attr) HandlerOption {
+	return func(h *CloudWatchLogHandler) {
+		h.replaceAttr = replace
+	}
+}
+
+// replaceAttrs returns r with the ReplaceAttr hook applied to its attributes.
+func (h *CloudWatchLogHandler) replaceAttrs(r slog.Record) slog.Record {
+	return replaceRecordAttrs(r, h.replaceAttr)
+}
+
+// replaceRecordAttrs returns a copy of r with replace applied to each of its
+// attributes, leaving out those replaced by an attribute with an empty key.
+func replaceRecordAttrs(r slog.Record, replace func(groups []string, a slog.Attr) slog.Attr) slog.Record {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	replaced := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	replaced.AddAttrs(replaceGroup(nil, attrs, replace)...)
+	return replaced
+}
+
+// replaceGroup applies replace to attrs nested in groups, leaving out dropped
+// attributes and groups left empty.
+func replaceGroup(groups []string, attrs []slog.Attr, replace func(groups []string, a slog.Attr) slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() == slog.KindGroup {
+			inner := groups
+			if a.Key != "" {
+				inner = append(groups[:len(groups):len(groups)], a.Key)
+			}
+			members := replaceGroup(inner, a.Value.Group(), replace)
+			if len(members) > 0 {
+				out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(members...)})
+			}
+			continue
+		}
+
+		a = replace(groups, a)
+		if a.Key == "" {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
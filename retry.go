@@ -0,0 +1,128 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+const (
+	// DefaultMaxRetries is the number of times a failed PutLogEvents call is retried.
+	DefaultMaxRetries = 3
+	// DefaultRetryBaseDelay is the backoff before the first retry.
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+	// DefaultRetryMaxDelay caps the backoff between retries.
+	DefaultRetryMaxDelay = 5 * time.Second
+
+	// DefaultStreamAttempts is the number of times creating a log stream is tried.
+	DefaultStreamAttempts = 3
+	// DefaultStreamRetryDelay is the average wait between attempts to create a log stream.
+	DefaultStreamRetryDelay = 2 * time.Second
+	// DefaultStreamRetryJitter is the fraction of the delay by which the wait
+	// between attempts to create a log stream is randomized.
+	DefaultStreamRetryJitter = 0.5
+)
+
+// putLogEvents calls PutLogEvents with the last known sequence token. A
+// rejected sequence token is corrected and the call retried once; events
+// CloudWatch reports as already accepted are not sent again.
+func (cw *CloudwatchClient) putLogEvents(ctx context.Context, stream *streamState, input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	input.SequenceToken = stream.sequenceToken
+	output, err := cw.putLogEventsWithBackoff(ctx, input)
+
+	var alreadyAccepted *types.DataAlreadyAcceptedException
+	var invalidToken *types.InvalidSequenceTokenException
+	switch {
+	case errors.As(err, &alreadyAccepted):
+		cw.opts.debugf("Log events were already accepted by CloudWatch, not sending them again")
+		stream.sequenceToken = alreadyAccepted.ExpectedSequenceToken
+		return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: alreadyAccepted.ExpectedSequenceToken}, nil
+	case errors.As(err, &invalidToken):
+		cw.opts.debugf("Invalid sequence token, retrying with the expected token")
+		input.SequenceToken = invalidToken.ExpectedSequenceToken
+		output, err = cw.putLogEventsWithBackoff(ctx, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stream.sequenceToken = output.NextSequenceToken
+	return output, nil
+}
+
+// putLogEventsWithBackoff calls PutLogEvents, retrying retryable failures with
+// exponential backoff and full jitter. The SDK's own retryer is disabled for
+// the call, so that WithMaxRetries and WithRetryDelay are the only retries.
+func (cw *CloudwatchClient) putLogEventsWithBackoff(ctx context.Context, input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	for attempt := 0; ; attempt++ {
+		output, err := cw.client.PutLogEvents(ctx, input, withoutSDKRetries)
+		if err == nil {
+			return output, nil
+		}
+		if attempt >= cw.opts.maxRetries || !isRetryable(err) {
+			return nil, err
+		}
+
+		cw.opts.metrics.Retry()
+		timer := time.NewTimer(backoff(attempt, cw.opts.retryBaseDelay, cw.opts.retryMaxDelay))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// Report the cancellation, which says nothing about CloudWatch,
+			// along with the failure that was about to be retried
+			timer.Stop()
+			return nil, errors.Join(ctx.Err(), err)
+		}
+	}
+}
+
+// withoutSDKRetries disables the retries of the SDK for a call.
+func withoutSDKRetries(o *cloudwatchlogs.Options) {
+	o.Retryer = aws.NopRetryer{}
+}
+
+// backoff returns a random delay between zero and base*2^attempt, capped at maxDelay.
+func backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	d := base << attempt
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
+
+// jitter returns d randomly moved by up to fraction of d either way, so
+// clients that failed together do not retry together.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	spread := float64(d) * fraction
+	return d + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// isRetryable reports whether err is a transient failure such as throttling,
+// a 5xx response or a timeout. Errors caused by the request itself, such as a
+// missing log group or stream, are never retried.
+func isRetryable(err error) bool {
+	var notFound *types.ResourceNotFoundException
+	var invalid *types.InvalidParameterException
+	if errors.As(err, &notFound) || errors.As(err, &invalid) {
+		return false
+	}
+
+	var unavailable *types.ServiceUnavailableException
+	if errors.As(err, &unavailable) {
+		return true
+	}
+
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
@@ -0,0 +1,77 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestPutLogEventsRetries(t *testing.T) {
+	cw, fake := newTestClient(t, WithMaxRetries(2), WithRetryDelay(time.Millisecond, time.Millisecond))
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		return nil, &types.ServiceUnavailableException{Message: aws.String("unavailable")}
+	})
+
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0)); err != nil {
+		t.Fatalf("EmitLog: %v", err)
+	}
+	var unavailable *types.ServiceUnavailableException
+	if err := cw.Flush(context.Background()); !errors.As(err, &unavailable) {
+		t.Fatalf("Flush = %v, want ServiceUnavailableException", err)
+	}
+
+	if calls := fake.putCalls(); len(calls) != 3 {
+		t.Errorf("got %d PutLogEvents calls, want 3", len(calls))
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, r := range fake.retryers {
+		if _, ok := r.(aws.NopRetryer); !ok {
+			t.Errorf("PutLogEvents called with SDK retryer %T, want aws.NopRetryer", r)
+		}
+	}
+}
+
+func TestPutLogEventsBackoffCancelled(t *testing.T) {
+	cw, fake := newTestClient(t, WithMaxRetries(5), WithRetryDelay(time.Hour, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		// Cancel while the client waits to retry
+		cancel()
+		return nil, &types.ServiceUnavailableException{Message: aws.String("unavailable")}
+	})
+
+	_, err := cw.putLogEventsWithBackoff(ctx, &cloudwatchlogs.PutLogEventsInput{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("putLogEventsWithBackoff = %v, want %v", err, context.Canceled)
+	}
+	if calls := fake.putCalls(); len(calls) != 1 {
+		t.Errorf("got %d PutLogEvents calls, want 1", len(calls))
+	}
+}
+
+func TestWithRetryDelay(t *testing.T) {
+	tests := []struct {
+		base, maxDelay    time.Duration
+		wantBase, wantMax time.Duration
+	}{
+		{time.Second, 10 * time.Second, time.Second, 10 * time.Second},
+		{time.Second, time.Millisecond, time.Second, time.Second},
+		{10 * time.Second, 0, 10 * time.Second, 10 * time.Second},
+		{0, 0, DefaultRetryBaseDelay, DefaultRetryMaxDelay},
+	}
+	for _, tt := range tests {
+		o := defaultOptions()
+		WithRetryDelay(tt.base, tt.maxDelay)(&o)
+		if o.retryBaseDelay != tt.wantBase || o.retryMaxDelay != tt.wantMax {
+			t.Errorf("WithRetryDelay(%v, %v) = %v, %v, want %v, %v", tt.base, tt.maxDelay, o.retryBaseDelay, o.retryMaxDelay, tt.wantBase, tt.wantMax)
+		}
+	}
+}
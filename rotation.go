@@ -0,0 +1,215 @@
+package slogcloud
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// DefaultRotationPrefix starts the names of daily log streams when no
+	// name is set with WithLogStream.
+	DefaultRotationPrefix = "slogcloud-stream"
+
+	// rotationRetryDelay is how long a client that failed to create the log
+	// stream of a new day waits before trying again.
+	rotationRetryDelay = time.Minute
+
+	// rotationTimeout bounds creating the log stream of a new day, retries
+	// included.
+	rotationTimeout = 30 * time.Second
+)
+
+// rotation is the outcome of creating the streams of the day under
+// WithDailyRotation.
+type rotation struct {
+	day     time.Time
+	streams []*streamState
+	err     error
+}
+
+// switchRequest asks the background goroutine to flush the client's streams
+// and replace them with streams.
+type switchRequest struct {
+	ctx     context.Context
+	streams []*streamState
+	done    chan error
+}
+
+// SetLogStream switches the client to the log stream name, for example to
+// rotate to a new stream every day, without recreating the client. The stream
+// is created, or appended to if it already exists, before the switch. Events
+// logged before SetLogStream is called are flushed to the old stream, and
+// events logged after it returns go to the new one; events logged meanwhile
+// go to either, never to a half-switched client. With WithStreamCount(n) the
+// n streams are renamed the same way as at creation, name-1 to name-n. The
+// error stream of WithErrorStream is kept.
+//
+// If flushing the old stream fails the switch still happens and the error is
+// returned, the events having been handled like those of any failed flush.
+// Creating the stream and flushing are bounded by a 30 second timeout; use
+// SetLogStreamCtx to choose your own.
+func (cw *CloudwatchClient) SetLogStream(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rotationTimeout)
+	defer cancel()
+	return cw.SetLogStreamCtx(ctx, name)
+}
+
+// SetLogStreamCtx is like SetLogStream but gives up with the context's error
+// once ctx is cancelled. If the stream was already created by then, the
+// switch may still happen.
+func (cw *CloudwatchClient) SetLogStreamCtx(ctx context.Context, name string) error {
+	select {
+	case <-cw.closing:
+		return ErrClientClosed
+	default:
+	}
+	if streamNames(name, cw.opts.streamCount)[0] == cw.currentLogStream() {
+		return nil
+	}
+	streams, err := cw.newStreams(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	req := switchRequest{ctx: ctx, streams: streams, done: make(chan error, 1)}
+	select {
+	case cw.switchReqs <- req:
+	case <-cw.closing:
+		return ErrClientClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newStreams creates the round-robin streams of a client whose log stream is
+// name, appending to those that already exist.
+func (cw *CloudwatchClient) newStreams(ctx context.Context, name string) ([]*streamState, error) {
+	if err := validateLogStreamName(name); err != nil {
+		return nil, err
+	}
+	names := streamNames(name, cw.opts.streamCount)
+	if cw.opts.errorStream != "" && slices.Contains(names, cw.opts.errorStream) {
+		return nil, fmt.Errorf("error stream %s is also a log stream of the client", cw.opts.errorStream)
+	}
+
+	streams := make([]*streamState, len(names))
+	for i, name := range names {
+		sequenceToken, err := createLogStream(ctx, cw.client, cw.logGroup, name, cw.opts)
+		if err != nil {
+			return nil, err
+		}
+		streams[i] = &streamState{group: cw.logGroup, name: name, sequenceToken: sequenceToken}
+	}
+	return streams, nil
+}
+
+// switchStreams sends the events queued and batched so far and replaces the
+// client's round-robin streams with streams. It is only called by the
+// background goroutine.
+func (cw *CloudwatchClient) switchStreams(ctx context.Context, streams []*streamState) error {
+	cw.drain()
+	err := cw.flushStreams(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to flush log stream %s before switching: %w", cw.logStream, err)
+	}
+	cw.replaceStreams(streams)
+	return err
+}
+
+// replaceStreams makes streams the client's round-robin streams. It is only
+// called by the background goroutine, once the old streams are flushed.
+func (cw *CloudwatchClient) replaceStreams(streams []*streamState) {
+	cw.streamsMu.Lock()
+	defer cw.streamsMu.Unlock()
+
+	copy(cw.streams, streams)
+	cw.logStream = streams[0].name
+	cw.opts.debugf("Switched to log stream %s", cw.logStream)
+}
+
+// rotateIfDue starts creating the stream of the next day, under
+// WithDailyRotation, once qe is timestamped at or after midnight. The stream
+// is created on its own goroutine so that sending is not held up meanwhile;
+// finishRotation switches to it. It is only called by the background
+// goroutine.
+func (cw *CloudwatchClient) rotateIfDue(qe queuedEvent) {
+	timestamp := aws.ToInt64(qe.event.Timestamp)
+	if !cw.opts.dailyRotation || cw.rotating || qe.replayed || timestamp < cw.nextRotation {
+		return
+	}
+
+	cw.rotating = true
+	day := time.UnixMilli(timestamp)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), rotationTimeout)
+		defer cancel()
+		streams, err := cw.newStreams(ctx, dailyStreamName(cw.opts, day))
+		select {
+		case cw.rotated <- rotation{day: day, streams: streams, err: err}:
+		case <-cw.closing:
+		}
+	}()
+}
+
+// finishRotation switches the client to the streams of the day created for
+// rot, sending the events batched until then to the old streams first. If
+// they could not be created, the client keeps its streams and tries again
+// after rotationRetryDelay. It is only called by the background goroutine.
+func (cw *CloudwatchClient) finishRotation(rot rotation) {
+	cw.rotating = false
+	if rot.err != nil {
+		cw.opts.debugf("Failed to rotate the log stream: %v", rot.err)
+		cw.nextRotation = rot.day.UnixMilli() + rotationRetryDelay.Milliseconds()
+		return
+	}
+
+	if err := cw.flushStreams(context.TODO()); err != nil {
+		cw.opts.debugf("Failed to flush log stream %s before rotating: %v", cw.logStream, err)
+	}
+	cw.replaceStreams(rot.streams)
+	cw.nextRotation = nextMidnight(rot.day, cw.opts.rotationLocation).UnixMilli()
+}
+
+// dailyStreamName returns the name of the log stream of the day t falls on
+// under WithDailyRotation, such as "app-2024-06-01".
+func dailyStreamName(o options, t time.Time) string {
+	return cmp.Or(o.logStream, DefaultRotationPrefix) + "-" + t.In(o.rotationLocation).Format(time.DateOnly)
+}
+
+// nextMidnight returns the start of the day after the one t falls on in loc.
+func nextMidnight(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+}
+
+// currentLogStream returns the name of the client's log stream.
+func (cw *CloudwatchClient) currentLogStream() string {
+	cw.streamsMu.RLock()
+	defer cw.streamsMu.RUnlock()
+	return cw.logStream
+}
+
+// streamNames returns the names of the count round-robin streams of a client
+// whose log stream is logStream.
+func streamNames(logStream string, count int) []string {
+	if count == 1 {
+		return []string{logStream}
+	}
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", logStream, i+1)
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package slogcloud
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// fixedClock is a Clock stopped at a point in time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestRotationLocationNil(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cw, _ := newTestClient(t,
+		WithClock(fixedClock(now)),
+		WithLogStream("app"),
+		WithDailyRotation(true),
+		WithRotationLocation(nil),
+	)
+	if got := cw.LogStreams()[0]; got != "app-2024-06-01" {
+		t.Errorf("log stream = %q, want %q", got, "app-2024-06-01")
+	}
+}
+
+func TestRotationDoesNotBlockSending(t *testing.T) {
+	midnight := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	cw, fake := newTestClient(t,
+		WithClock(fixedClock(midnight.Add(-time.Minute))),
+		WithLogStream("app"),
+		WithDailyRotation(true),
+		WithFlushInterval(time.Hour),
+	)
+	oldStream := cw.LogStreams()[0]
+	newStream := dailyStreamName(cw.opts, midnight)
+
+	created := make(chan struct{})
+	fake.setCreateStream(func(ctx context.Context, in *cloudwatchlogs.CreateLogStreamInput) error {
+		select {
+		case <-created:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	emit := func(msg string) {
+		t.Helper()
+		if err := cw.EmitLog(slog.NewRecord(midnight.Add(time.Second), slog.LevelInfo, msg, 0)); err != nil {
+			t.Fatalf("EmitLog: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := cw.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+
+	// The new stream is still being created, so the log goes to the old one
+	emit("while creating")
+	close(created)
+	deadline := time.Now().Add(5 * time.Second)
+	for cw.LogStreams()[0] != newStream && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	emit("after creating")
+
+	calls := fake.putCalls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d PutLogEvents calls, want 2", len(calls))
+	}
+	if got := aws.ToString(calls[0].LogStreamName); got != oldStream {
+		t.Errorf("log during creation sent to %q, want %q", got, oldStream)
+	}
+	if got := aws.ToString(calls[1].LogStreamName); got != newStream {
+		t.Errorf("log after creation sent to %q, want %q", got, newStream)
+	}
+}
+
+func TestSetLogStreamCtxCancelled(t *testing.T) {
+	cw, fake := newTestClient(t, WithLogStream("app"))
+	fake.setCreateStream(func(ctx context.Context, _ *cloudwatchlogs.CreateLogStreamInput) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := cw.SetLogStreamCtx(ctx, "next"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SetLogStreamCtx = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := cw.LogStreams()[0]; got != "app" {
+		t.Errorf("log stream = %q, want %q", got, "app")
+	}
+}
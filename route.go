@@ -0,0 +1,140 @@
+package slogcloud
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultMaxRoutes is the default number of routed log streams kept open by
+// a client with a Router.
+const DefaultMaxRoutes = 100
+
+// Router picks the log group and stream a record is sent to, for example a
+// log group per tenant. An empty group means the client's log group and an
+// empty stream the client's log stream name.
+type Router func(r slog.Record) (group, stream string)
+
+// routeKey identifies a routed log stream.
+type routeKey struct {
+	group  string
+	stream string
+}
+
+// route returns where the router sends r, or nil if it sends it to the
+// client's own streams.
+func (cw *CloudwatchClient) route(r slog.Record) *routeKey {
+	group, stream := cw.opts.router(r)
+	logStream := cw.currentLogStream()
+	if group == "" {
+		group = cw.logGroup
+	}
+	if stream == "" {
+		stream = logStream
+	}
+	if group == cw.logGroup && stream == logStream {
+		return nil
+	}
+	return &routeKey{group: group, stream: stream}
+}
+
+// groupEnabled reports whether a record at level may be sent to the log group
+// of route, the client's log group if route is nil, under the levels of
+// WithMinLevelPerGroup.
+func (cw *CloudwatchClient) groupEnabled(route *routeKey, level slog.Level) bool {
+	if len(cw.opts.groupLevels) == 0 {
+		return true
+	}
+	group := cw.logGroup
+	if route != nil {
+		group = route.group
+	}
+	threshold, ok := cw.opts.groupLevels[group]
+	return !ok || level >= threshold.Level()
+}
+
+// streamFor returns the stream qe is batched on, creating the log group and
+// stream of a new route. It returns nil if the route could not be created, in
+// which case the event has been reported as failed. It is only called by the
+// background goroutine.
+func (cw *CloudwatchClient) streamFor(qe queuedEvent) *streamState {
+	if qe.route == nil {
+		return cw.streams[qe.stream]
+	}
+
+	cw.routeUses++
+	if stream, ok := cw.routes[*qe.route]; ok {
+		stream.lastUsed = cw.routeUses
+		return stream
+	}
+
+	stream, err := cw.openRoute(context.TODO(), *qe.route)
+	if err != nil {
+		cw.opts.debugf("Failed to create route to log group %s and stream %s: %v", qe.route.group, qe.route.stream, err)
+		cw.opts.metrics.LogsFailed(1)
+		cw.writeFallback([]queuedEvent{qe})
+		cw.reportError(err, qe.record)
+		return nil
+	}
+
+	if cw.routes == nil {
+		cw.routes = make(map[routeKey]*streamState)
+	}
+	if len(cw.routes) >= cw.opts.maxRoutes {
+		cw.evictRoute()
+	}
+	stream.lastUsed = cw.routeUses
+	cw.routes[*qe.route] = stream
+	return stream
+}
+
+// openRoute ensures the log group of key exists, the first time it is used,
+// and creates its log stream.
+func (cw *CloudwatchClient) openRoute(ctx context.Context, key routeKey) (*streamState, error) {
+	if err := validateLogGroupName(key.group); err != nil {
+		return nil, err
+	}
+	if err := validateLogStreamName(key.stream); err != nil {
+		return nil, err
+	}
+
+	if _, ok := cw.groups[key.group]; !ok && key.group != cw.logGroup {
+		if err := ensureLogGroup(ctx, cw.client, key.group, cw.opts); err != nil {
+			return nil, fmt.Errorf("failed to ensure log group %s: %w", key.group, err)
+		}
+		if cw.groups == nil {
+			cw.groups = make(map[string]struct{})
+		}
+		if len(cw.groups) >= cw.opts.maxRoutes {
+			// Forgetting a group only costs another check if it is used again
+			for group := range cw.groups {
+				delete(cw.groups, group)
+				break
+			}
+		}
+		cw.groups[key.group] = struct{}{}
+	}
+
+	sequenceToken, err := createLogStream(ctx, cw.client, key.group, key.stream, cw.opts)
+	if err != nil {
+		return nil, err
+	}
+	return &streamState{group: key.group, name: key.stream, sequenceToken: sequenceToken}, nil
+}
+
+// evictRoute sends the pending events of the least recently used route and
+// closes it to make room for a new one.
+func (cw *CloudwatchClient) evictRoute() {
+	var oldest routeKey
+	var stream *streamState
+	for key, s := range cw.routes {
+		if stream == nil || s.lastUsed < stream.lastUsed {
+			oldest, stream = key, s
+		}
+	}
+
+	if err := cw.sendBatch(context.TODO(), stream); err != nil {
+		cw.opts.debugf("Failed to flush log events: %v", err)
+	}
+	delete(cw.routes, oldest)
+}
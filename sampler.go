@@ -0,0 +1,109 @@
+package slogcloud
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxSampledMessages bounds how many distinct messages the rate sampler tracks.
+const maxSampledMessages = 10000
+
+// Sampler decides whether a record is sent. Records it rejects are dropped
+// before they are formatted or queued. Implementations must be safe for
+// concurrent use.
+type Sampler interface {
+	Sample(r slog.Record) bool
+}
+
+// WithSampler drops the records sampler rejects, to reduce the volume of
+// high-frequency logs.
+func WithSampler(sampler Sampler) HandlerOption {
+	return func(h *CloudWatchLogHandler) {
+		h.sampler = sampler
+	}
+}
+
+// countingSampler keeps one out of every n records at or below level.
+type countingSampler struct {
+	level slog.Level
+	n     uint64
+	count atomic.Uint64
+}
+
+// NewCountingSampler returns a Sampler that keeps the first of every n
+// records at or below level. Records above level are always kept.
+func NewCountingSampler(level slog.Level, n int) Sampler {
+	if n < 1 {
+		n = 1
+	}
+	return &countingSampler{level: level, n: uint64(n)}
+}
+
+// Sample implements Sampler.
+func (s *countingSampler) Sample(r slog.Record) bool {
+	if r.Level > s.level {
+		return true
+	}
+	return (s.count.Add(1)-1)%s.n == 0
+}
+
+// rateSampler keeps at most perSecond records per message, using a token
+// bucket for every distinct message.
+type rateSampler struct {
+	level     slog.Level
+	perSecond float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateSampler returns a Sampler that keeps at most perSecond records per
+// second for each distinct message at or below level, allowing bursts of up
+// to perSecond records. Records above level are always kept.
+func NewRateSampler(level slog.Level, perSecond int) Sampler {
+	if perSecond < 1 {
+		perSecond = 1
+	}
+	return &rateSampler{
+		level:     level,
+		perSecond: float64(perSecond),
+		buckets:   make(map[string]*tokenBucket),
+	}
+}
+
+// Sample implements Sampler.
+func (s *rateSampler) Sample(r slog.Record) bool {
+	if r.Level > s.level {
+		return true
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[r.Message]
+	if !ok {
+		// Start over rather than grow without bound on highly variable messages
+		if len(s.buckets) >= maxSampledMessages {
+			clear(s.buckets)
+		}
+		b = &tokenBucket{tokens: s.perSecond, last: now}
+		s.buckets[r.Message] = b
+	}
+
+	b.tokens = min(s.perSecond, b.tokens+now.Sub(b.last).Seconds()*s.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
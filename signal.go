@@ -0,0 +1,67 @@
+package slogcloud
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// FlushOnSignal closes the client, sending every buffered log, when the
+// process receives one of sigs, by default SIGTERM and os.Interrupt. It is
+// meant for containers, which get SIGTERM and a short grace period before
+// being killed, so logs still in the buffer are not lost.
+//
+// Handling a signal stops it from terminating the program, so the program
+// must handle it too, for example with signal.NotifyContext, and shut down in
+// its own way. Programs that do not should use FlushOnSignalAndReraise. The
+// returned function uninstalls the handler; it is safe to call more than once.
+func (cw *CloudwatchClient) FlushOnSignal(sigs ...os.Signal) (stop func()) {
+	return cw.flushOnSignal(false, sigs)
+}
+
+// FlushOnSignalAndReraise is like FlushOnSignal but raises the signal again
+// once the client is closed, so a program that does not handle it itself still
+// terminates as usual.
+func (cw *CloudwatchClient) FlushOnSignalAndReraise(sigs ...os.Signal) (stop func()) {
+	return cw.flushOnSignal(true, sigs)
+}
+
+// flushOnSignal installs the handler of FlushOnSignal, raising the signal
+// again after closing the client if reraise is set.
+func (cw *CloudwatchClient) flushOnSignal(reraise bool, sigs []os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+
+	received := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(received, sigs...)
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+		})
+	}
+
+	go func() {
+		select {
+		case sig := <-received:
+			if err := cw.Close(); err != nil {
+				cw.opts.debugf("Failed to flush log events on %v: %v", sig, err)
+			}
+			stop()
+			if !reraise {
+				return
+			}
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				_ = p.Signal(sig)
+			}
+		case <-done:
+		}
+	}()
+
+	return stop
+}
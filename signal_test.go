@@ -0,0 +1,56 @@
+//go:build unix
+
+package slogcloud
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFlushOnSignal(t *testing.T) {
+	// The test handles the signal too, as FlushOnSignal expects
+	own := make(chan os.Signal, 1)
+	signal.Notify(own, syscall.SIGUSR1)
+	defer signal.Stop(own)
+
+	cw, fake := newTestClient(t, WithFlushInterval(time.Hour))
+	stop := cw.FlushOnSignal(syscall.SIGUSR1)
+	defer stop()
+
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "shutting down", 0)); err != nil {
+		t.Fatalf("EmitLog: %v", err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-own:
+	case <-time.After(5 * time.Second):
+		t.Fatal("program did not receive the signal")
+	}
+	select {
+	case <-cw.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client was not closed")
+	}
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("EmitLog after the signal = %v, want %v", err, ErrClientClosed)
+	}
+	if got := fake.messages(); len(got) != 1 {
+		t.Errorf("sent %q, want the buffered log", got)
+	}
+
+	// Nothing is raised again: the process is still running and the
+	// program's handler gets no second signal
+	select {
+	case sig := <-own:
+		t.Errorf("signal %v raised again", sig)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
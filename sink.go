@@ -0,0 +1,41 @@
+package slogcloud
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+// LogSink is a destination for log records, such as CloudWatch, a file or a
+// test recorder. A sink that buffers records may also implement
+// Flush(ctx context.Context) error and io.Closer, which the handler uses to
+// send pending records on Fatal and Close.
+type LogSink interface {
+	Emit(ctx context.Context, r slog.Record) error
+}
+
+// flusher is implemented by sinks that buffer records.
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Emit queues a log record to be sent to CloudWatch, implementing LogSink.
+func (cw *CloudwatchClient) Emit(ctx context.Context, r slog.Record) error {
+	return cw.EmitLogCtx(ctx, r)
+}
+
+// Flush sends pending records if the sink buffers them.
+func (h *CloudWatchLogHandler) Flush(ctx context.Context) error {
+	if f, ok := h.sink.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// Close flushes and closes the sink if it holds resources.
+func (h *CloudWatchLogHandler) Close() error {
+	if c, ok := h.sink.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
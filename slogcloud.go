@@ -1,19 +1,25 @@
 package slogcloud
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"log/slog"
+	"net/url"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/google/uuid"
 )
 
@@ -21,260 +27,1298 @@ import (
 const (
 	PROD = "prod"
 	DEV  = "dev"
+	// STAGING logs to CloudWatch and, as text, to stdout at the same time.
+	STAGING = "staging"
 )
 
+// DefaultTimeFormat is the layout of the time StdLogger prints with each JSON
+// log: RFC 3339 with milliseconds.
+const DefaultTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// fatalFlushTimeout bounds how long Fatal waits for pending logs to be sent.
+const fatalFlushTimeout = 5 * time.Second
+
+// startupPingTimeout bounds how long creating a client waits for the startup
+// log enabled by WithStartupPing to be sent.
+const startupPingTimeout = 30 * time.Second
+
+// imdsRegionTimeout bounds how long looking up the region of the EC2 instance
+// may take, as off EC2 the instance metadata endpoint never answers.
+const imdsRegionTimeout = 2 * time.Second
+
+// SeqKey is the attribute key that holds the sequence number of a log when
+// WithSequenceNumbers is set.
+const SeqKey = "seq"
+
+// HostKey is the attribute key that holds the hostname of every log when
+// WithHostname is set.
+const HostKey = "host"
+
+// LevelFatal is the level Fatal logs at. It is emitted as "FATAL".
+const LevelFatal = slog.Level(12)
+
+// DefaultLevelKey is the JSON key the level of a log is stored under.
+const DefaultLevelKey = "level"
+
+// levelString returns the name of level, naming LevelFatal "FATAL" rather than "ERROR+4".
+func levelString(level slog.Level) string {
+	if level == LevelFatal {
+		return "FATAL"
+	}
+	return level.String()
+}
+
 // Logger is the interface that defines multiple log levels.
+// Every level accepts optional key/value pairs, slog.Attr values or Fields,
+// which are added to the log as structured attributes.
+// Error and Fatal take the error first and store it under "error".
 type Logger interface {
-	Debug(msg string)
-	Info(msg string)
-	Warn(msg string)
-	Error(msg string, err error)
-	Fatal(msg string, err error)
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, err error, args ...any)
+	Fatal(msg string, err error, args ...any)
+
+	// The Context variants pass ctx to the handler, so request-scoped values
+	// and cancellation reach the CloudWatch client.
+	DebugContext(ctx context.Context, msg string, args ...any)
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, err error, args ...any)
+	FatalContext(ctx context.Context, msg string, err error, args ...any)
+
+	// With returns a logger that adds args, key/value pairs or slog.Attr
+	// values, to every log. It writes to the same destination and shares the
+	// OnFatal hooks and exit function of the logger it was derived from.
+	With(args ...any) Logger
+	// WithFields is like With with the attributes given as Fields, for code
+	// coming from logrus.
+	WithFields(fields Fields) Logger
+
+	Close() error
+}
+
+// errorArgs prepends err, if any, to args under the "error" key so CloudWatch
+// has a specific error key.
+func errorArgs(err error, args []any) []any {
+	if err == nil {
+		return args
+	}
+	return append([]any{slog.Any("error", err)}, args...)
+}
+
+// CloudwatchAPI is the subset of the CloudWatch Logs API used by CloudwatchClient.
+// It is satisfied by *cloudwatchlogs.Client and can be replaced by a fake in tests.
+type CloudwatchAPI interface {
+	PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error)
+	CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error)
+	CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error)
+	DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error)
+	PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+	DeleteRetentionPolicy(ctx context.Context, params *cloudwatchlogs.DeleteRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteRetentionPolicyOutput, error)
+	DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error)
+	TagLogGroup(ctx context.Context, params *cloudwatchlogs.TagLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagLogGroupOutput, error)
+	AssociateKmsKey(ctx context.Context, params *cloudwatchlogs.AssociateKmsKeyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.AssociateKmsKeyOutput, error)
 }
 
 // CloudwatchClient represents the AWS CloudWatch Logs client.
+//
+// A CloudwatchClient is safe for concurrent use by multiple goroutines.
+// Emitting a log only hands it to a channel; the batches, sequence tokens and
+// streams are owned by a single background goroutine, so they are never
+// shared between callers.
+//
+// Each event keeps the time of its record, taken when it was logged rather
+// than when it is sent. Within a stream, events are sent in chronological
+// order and events logged in the same millisecond in the order they were
+// emitted. An event that reaches the queue after a later event of its stream
+// was already sent, as can happen when logging concurrently, is given that
+// event's time. Ordering across streams is best effort.
 type CloudwatchClient struct {
 	logStream string
 	logGroup  string
-	client    *cloudwatchlogs.Client
+	client    CloudwatchAPI
+	opts      options
+
+	// streams are the log streams events are distributed across; logStream is the first
+	streams    []*streamState
+	nextStream atomic.Uint64
+
+	// queue holds events waiting to be batched by the background flusher.
+	queue     chan queuedEvent
+	flushReqs chan flushRequest
+	closing   chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	// switchReqs carries the streams SetLogStream switches to. The background
+	// goroutine changes logStream and streams holding streamsMu, which other
+	// goroutines hold to read them.
+	switchReqs chan switchRequest
+	streamsMu  sync.RWMutex
+
+	// nextRotation is when WithDailyRotation next switches streams, in
+	// milliseconds since the epoch, and rotating whether the streams of the
+	// next day are being created, both only accessed by the background
+	// goroutine. rotated carries the created streams to it.
+	nextRotation int64
+	rotating     bool
+	rotated      chan rotation
+
+	// seq is the last sequence number given out by WithSequenceNumbers
+	seq atomic.Uint64
+
+	// disk holds events that failed to send, if WithDiskBuffer is set, and
+	// sendFailed whether the last PutLogEvents call failed
+	disk       *diskBuffer
+	sendFailed atomic.Bool
+
+	// highWater is the most events the queue has held; queueWarned is set
+	// while the queue is above the WithQueueWarning threshold
+	highWater   atomic.Int64
+	queueWarned atomic.Bool
+
+	// errs carries failures to the goroutine that calls the OnError callback.
+	errs       chan failedRecord
+	errorsDone chan struct{}
+
+	fallbackMu     sync.Mutex
+	fallbackWrites atomic.Uint64
+
+	breaker breaker
+
+	// routes are the streams created for the router, only accessed by the
+	// background goroutine
+	routes    map[routeKey]*streamState
+	groups    map[string]struct{}
+	routeUses uint64
 }
 
 // SlogLogger implements the Logger interface using the slog library.
 type SlogLogger struct {
+	// fatalExit is shared with the loggers derived by WithName and WithLevel
+	*fatalExit
 	handler *CloudWatchLogHandler
+	logger  *slog.Logger
+
+	// unnamed is logger without the name attribute, so nested names compose
+	unnamed *slog.Logger
+	name    string
+}
+
+// NewLoggerWithSink returns a Logger that sends every log to sink, so the
+// Logger abstraction can be used with backends other than CloudWatch.
+func NewLoggerWithSink(sink LogSink, opts ...HandlerOption) *SlogLogger {
+	return newSlogLogger(NewHandler(sink, opts...))
+}
+
+func newSlogLogger(handler *CloudWatchLogHandler) *SlogLogger {
+	logger := slog.New(handler)
+	return &SlogLogger{fatalExit: &fatalExit{}, handler: handler, logger: logger, unnamed: logger}
 }
 
 // Debug logs a debug message.
-func (s *SlogLogger) Debug(msg string) {
-	slog.Debug(msg)
+func (s *SlogLogger) Debug(msg string, args ...any) {
+	s.log(context.Background(), 1, slog.LevelDebug, msg, args...)
 }
 
 // Info logs an info message.
-func (s *SlogLogger) Info(msg string) {
-	slog.Info(msg)
+func (s *SlogLogger) Info(msg string, args ...any) {
+	s.log(context.Background(), 1, slog.LevelInfo, msg, args...)
 }
 
 // Warn logs a warning message.
-func (s *SlogLogger) Warn(msg string) {
-	slog.Warn(msg)
+func (s *SlogLogger) Warn(msg string, args ...any) {
+	s.log(context.Background(), 1, slog.LevelWarn, msg, args...)
 }
 
 // Error logs an error message.
-func (s *SlogLogger) Error(msg string, err error) {
-	if err != nil {
-		// We pass this for AWS to have a specific error key
-		slog.Error(msg, slog.String("error", err.Error()))
-	} else {
-		slog.Error(msg)
+func (s *SlogLogger) Error(msg string, err error, args ...any) {
+	s.log(context.Background(), 1, slog.LevelError, msg, errorArgs(err, args)...)
+}
+
+// Fatal logs a fatal error message, flushes pending logs and exits the program.
+func (s *SlogLogger) Fatal(msg string, err error, args ...any) {
+	s.fatal(context.Background(), s.exitCode(), msg, err, args...)
+}
+
+// FatalCode is like Fatal but exits the program with code.
+func (s *SlogLogger) FatalCode(msg string, err error, code int, args ...any) {
+	s.fatal(context.Background(), code, msg, err, args...)
+}
+
+// DebugContext logs a debug message with the given context.
+func (s *SlogLogger) DebugContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, 1, slog.LevelDebug, msg, args...)
+}
+
+// InfoContext logs an info message with the given context.
+func (s *SlogLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, 1, slog.LevelInfo, msg, args...)
+}
+
+// WarnContext logs a warning message with the given context.
+func (s *SlogLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	s.log(ctx, 1, slog.LevelWarn, msg, args...)
+}
+
+// ErrorContext logs an error message with the given context.
+func (s *SlogLogger) ErrorContext(ctx context.Context, msg string, err error, args ...any) {
+	s.log(ctx, 1, slog.LevelError, msg, errorArgs(err, args)...)
+}
+
+// FatalContext logs a fatal error message with the given context, runs the
+// OnFatal hooks, flushes pending logs and exits the program.
+func (s *SlogLogger) FatalContext(ctx context.Context, msg string, err error, args ...any) {
+	s.fatal(ctx, s.exitCode(), msg, err, args...)
+}
+
+// With returns a logger that adds args to every log, sending to the same
+// handler and CloudWatch client as s.
+func (s *SlogLogger) With(args ...any) Logger {
+	if len(args) == 0 {
+		return s
+	}
+	args = expandFields(args)
+	return &SlogLogger{
+		fatalExit: s.fatalExit,
+		handler:   s.handler,
+		logger:    s.logger.With(args...),
+		unnamed:   s.unnamed.With(args...),
+		name:      s.name,
+	}
+}
+
+// WithFields returns a logger that adds fields to every log.
+func (s *SlogLogger) WithFields(fields Fields) Logger {
+	return s.With(fields)
+}
+
+// log emits a record whose source is skip frames above the caller of log, so
+// WithSource reports the code that called the Logger rather than this wrapper.
+func (s *SlogLogger) log(ctx context.Context, skip int, level slog.Level, msg string, args ...any) {
+	if !s.logger.Enabled(ctx, level) {
+		return
 	}
+
+	// Skip runtime.Callers, log and the skipped wrappers
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(expandFields(args)...)
+	_ = s.logger.Handler().Handle(ctx, r)
 }
 
-// Fatal logs a fatal error message and exits the program.
-func (s *SlogLogger) Fatal(msg string, err error) {
-	slog.Error(msg, slog.Any("fatal", err))
-	os.Exit(1)
+// fatal implements Fatal, FatalCode and FatalContext.
+func (s *SlogLogger) fatal(ctx context.Context, code int, msg string, err error, args ...any) {
+	// Log and flush even if ctx is already done so the fatal error still gets out
+	ctx = context.WithoutCancel(ctx)
+	s.log(ctx, 2, LevelFatal, msg, errorArgs(err, args)...)
+	s.runHooks()
+
+	flushCtx, cancel := context.WithTimeout(ctx, fatalFlushTimeout)
+	defer cancel()
+	s.handler.Flush(flushCtx)
+
+	s.exitWith(code)
+}
+
+// Close flushes pending logs and releases the sink.
+func (s *SlogLogger) Close() error {
+	return s.handler.Close()
 }
 
 // StdLogger implements the Logger interface for non-production environments (console output).
-type StdLogger struct{}
+// By default each log is printed as the same JSON document that is sent to
+// CloudWatch, with the time it was logged under "time".
+type StdLogger struct {
+	// fatalExit is shared with the loggers derived by With
+	*fatalExit
+	w       io.Writer
+	console *ConsoleHandler
+
+	// attrs are the arguments added by With, printed before each log's own
+	attrs []any
+
+	text        bool
+	consoleOpts []ConsoleOption
+
+	// timeFormat and utc control how the time of each log is printed
+	timeFormat string
+	utc        bool
+
+	// levelWriters replace the writer for logs at or above their level
+	levelWriters []levelWriter
+}
+
+// StdOption configures a StdLogger.
+type StdOption func(*StdLogger)
+
+// WithTextOutput makes the StdLogger print human-readable lines with a
+// timestamp, a colorized level and key=value attributes instead of JSON, as
+// ConsoleHandler does. opts configure the console output, for example
+// WithColor(false).
+func WithTextOutput(opts ...ConsoleOption) StdOption {
+	return func(l *StdLogger) {
+		l.text = true
+		l.consoleOpts = opts
+	}
+}
+
+// WithTimeFormat sets the layout, as used by time.Format, of the time printed
+// with each log, for example "15:04:05.000" or time.RFC3339Nano. JSON output
+// defaults to DefaultTimeFormat; text output to ConsoleHandler's default.
+func WithTimeFormat(layout string) StdOption {
+	return func(l *StdLogger) {
+		l.timeFormat = layout
+	}
+}
+
+// WithUTC prints times in UTC instead of the local time zone.
+func WithUTC(utc bool) StdOption {
+	return func(l *StdLogger) {
+		l.utc = utc
+	}
+}
+
+// WithWriter makes the StdLogger write to w instead of stdout, for example a
+// file or a buffer inspected by a test.
+func WithWriter(w io.Writer) StdOption {
+	return func(l *StdLogger) {
+		l.w = w
+	}
+}
+
+// WithLevelWriter writes logs at or above level to w instead of stdout, for
+// example WithLevelWriter(slog.LevelWarn, os.Stderr) to print warnings, errors
+// and fatal errors to stderr like most command-line tools. When given several
+// times, a log goes to the writer with the highest level it reaches.
+func WithLevelWriter(level slog.Level, w io.Writer) StdOption {
+	return func(l *StdLogger) {
+		l.levelWriters = append(l.levelWriters, levelWriter{level: level, w: w})
+	}
+}
+
+// NewStdLogger creates a StdLogger that writes to stdout, or to the writer
+// given with WithWriter.
+func NewStdLogger(opts ...StdOption) *StdLogger {
+	l := &StdLogger{fatalExit: &fatalExit{}}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.text {
+		consoleOpts := l.consoleOpts
+		if l.timeFormat != "" {
+			consoleOpts = append(consoleOpts[:len(consoleOpts):len(consoleOpts)], WithConsoleTimeFormat(l.timeFormat))
+		}
+		if l.utc {
+			consoleOpts = append(consoleOpts[:len(consoleOpts):len(consoleOpts)], WithConsoleUTC(true))
+		}
+		for _, lw := range l.levelWriters {
+			consoleOpts = append(consoleOpts[:len(consoleOpts):len(consoleOpts)], WithConsoleLevelWriter(lw.level, lw.w))
+		}
+		l.console = NewConsoleHandler(l.writer(), consoleOpts...)
+	}
+	return l
+}
+
+// writer returns the writer logs are printed to, stdout unless WithWriter was given.
+func (l *StdLogger) writer() io.Writer {
+	if l.w == nil {
+		return os.Stdout
+	}
+	return l.w
+}
 
 // Debug logs a debug message to stdout.
-func (l *StdLogger) Debug(msg string) {
-	fmt.Println("DEBUG:", msg)
+func (l *StdLogger) Debug(msg string, args ...any) {
+	l.print(slog.LevelDebug, msg, args...)
 }
 
 // Info logs an info message to stdout.
-func (l *StdLogger) Info(msg string) {
-	fmt.Println("INFO:", msg)
+func (l *StdLogger) Info(msg string, args ...any) {
+	l.print(slog.LevelInfo, msg, args...)
 }
 
 // Warn logs a warning message to stdout.
-func (l *StdLogger) Warn(msg string) {
-	fmt.Println("WARN:", msg)
+func (l *StdLogger) Warn(msg string, args ...any) {
+	l.print(slog.LevelWarn, msg, args...)
 }
 
 // Error logs an error message to stdout.
-func (l *StdLogger) Error(msg string, err error) {
-	fmt.Println("ERROR:", msg, err)
+func (l *StdLogger) Error(msg string, err error, args ...any) {
+	l.print(slog.LevelError, msg, errorArgs(err, args)...)
+}
+
+// Fatal logs a fatal error message to stdout, runs the OnFatal hooks and exits the program.
+func (l *StdLogger) Fatal(msg string, err error, args ...any) {
+	l.FatalCode(msg, err, l.exitCode(), args...)
+}
+
+// FatalCode is like Fatal but exits the program with code.
+func (l *StdLogger) FatalCode(msg string, err error, code int, args ...any) {
+	l.print(LevelFatal, msg, errorArgs(err, args)...)
+	l.runHooks()
+	l.exitWith(code)
+}
+
+// DebugContext logs a debug message to stdout. The context is ignored.
+func (l *StdLogger) DebugContext(_ context.Context, msg string, args ...any) {
+	l.Debug(msg, args...)
 }
 
-// Fatal logs a fatal error message to stdout and exits the program.
-func (l *StdLogger) Fatal(msg string, err error) {
-	fmt.Println("FATAL:", msg, err)
-	os.Exit(1)
+// InfoContext logs an info message to stdout. The context is ignored.
+func (l *StdLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+// WarnContext logs a warning message to stdout. The context is ignored.
+func (l *StdLogger) WarnContext(_ context.Context, msg string, args ...any) {
+	l.Warn(msg, args...)
+}
+
+// ErrorContext logs an error message to stdout. The context is ignored.
+func (l *StdLogger) ErrorContext(_ context.Context, msg string, err error, args ...any) {
+	l.Error(msg, err, args...)
+}
+
+// FatalContext logs a fatal error message to stdout and exits the program.
+// The context is ignored.
+func (l *StdLogger) FatalContext(_ context.Context, msg string, err error, args ...any) {
+	l.Fatal(msg, err, args...)
+}
+
+// With returns a logger that prints args with every log, to the same output
+// as l.
+func (l *StdLogger) With(args ...any) Logger {
+	if len(args) == 0 {
+		return l
+	}
+	l2 := *l
+	l2.attrs = append(l.attrs[:len(l.attrs):len(l.attrs)], expandFields(args)...)
+	return &l2
+}
+
+// WithFields returns a logger that prints fields with every log.
+func (l *StdLogger) WithFields(fields Fields) Logger {
+	return l.With(fields)
+}
+
+// Close is a no-op as nothing is buffered for stdout.
+func (l *StdLogger) Close() error {
+	return nil
+}
+
+// print prints the log either in the same JSON shape EmitLog sends to
+// CloudWatch or, with WithTextOutput, as a console line.
+func (l *StdLogger) print(level slog.Level, msg string, args ...any) {
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.Add(l.attrs...)
+	r.Add(expandFields(args)...)
+	if l.console != nil {
+		_ = l.console.Handle(context.Background(), r)
+		return
+	}
+	entry := buildLogEntry(r, DefaultLevelKey, errorFormat{})
+	entry[slog.TimeKey] = formatTime(r.Time, cmp.Or(l.timeFormat, DefaultTimeFormat), l.utc)
+	data, _ := marshalEntry(entry) // Values JSON cannot represent are already written as text
+	releaseLogEntry(entry)
+	fmt.Fprintln(writerFor(l.writer(), l.levelWriters, level), string(data))
 }
 
 // CloudWatchLogHandler is the handler that sends logs to AWS CloudWatch.
+// It is safe for concurrent use, as is any slog.Handler.
 type CloudWatchLogHandler struct {
-	client *CloudwatchClient
+	sink   LogSink
+	level  *slog.LevelVar
+	attrs  []slog.Attr
+	groups []string
+
+	// extractors derive attributes, such as trace IDs, from the context passed to Handle
+	extractors []func(ctx context.Context) []slog.Attr
+
+	sampler     Sampler
+	replaceAttr func(groups []string, a slog.Attr) slog.Attr
 }
 
-// Handle processes and sends logs to CloudWatch.
+// Handle processes and sends logs to CloudWatch. Records below the handler's
+// level are discarded without being formatted or sent, even when Handle is
+// called directly or by a wrapping handler that does not check Enabled first.
 func (h *CloudWatchLogHandler) Handle(ctx context.Context, r slog.Record) error {
-	return h.client.EmitLog(r)
+	if !h.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	if h.sampler != nil && !h.sampler.Sample(r) {
+		if d, ok := h.sink.(dropRecorder); ok {
+			d.recordDrop(DropSampled)
+		}
+		return nil
+	}
+
+	if len(h.attrs) > 0 || len(h.groups) > 0 || len(h.extractors) > 0 {
+		// Context and handler attributes come first so the record's own attributes win on conflicting keys
+		merged := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		for _, extract := range h.extractors {
+			merged.AddAttrs(extract(ctx)...)
+		}
+		merged.AddAttrs(h.attrs...)
+
+		attrs := make([]slog.Attr, 0, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = append(attrs, a)
+			return true
+		})
+		merged.AddAttrs(h.nest(attrs)...)
+		r = merged
+	}
+
+	if h.replaceAttr != nil {
+		r = h.replaceAttrs(r)
+	}
+
+	return h.sink.Emit(ctx, r)
+}
+
+// nest wraps attrs in the groups opened on the handler, innermost group first.
+func (h *CloudWatchLogHandler) nest(attrs []slog.Attr) []slog.Attr {
+	if len(attrs) == 0 {
+		return nil
+	}
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+	return attrs
 }
 
-// Enabled returns true to allow logging for all levels.
+// Enabled reports whether the level is at or above the handler's minimum level.
 func (h *CloudWatchLogHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return true
+	return level >= h.level.Level()
 }
 
-// WithAttrs is used for setting attributes in a group.
+// SetLevel changes the minimum level of the handler at runtime.
+func (h *CloudWatchLogHandler) SetLevel(level slog.Level) {
+	h.level.Set(level)
+}
+
+// LevelVar returns the variable holding the minimum level of the handler,
+// shared with the handlers derived from it by WithAttrs and WithGroup.
+// Changing it, for example through LevelHandler, takes effect immediately.
+func (h *CloudWatchLogHandler) LevelVar() *slog.LevelVar {
+	return h.level
+}
+
+// WithAttrs returns a handler that adds attrs to every record it handles.
 func (h *CloudWatchLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return h
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], h.nest(attrs)...)
+	return &h2
 }
 
-// WithGroup sets the group name for structured logs.
+// WithGroup returns a handler that nests all subsequent attributes under name.
 func (h *CloudWatchLogHandler) WithGroup(name string) slog.Handler {
-	return h
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// HandlerOption configures a CloudWatchLogHandler.
+type HandlerOption func(*CloudWatchLogHandler)
+
+// WithLevel sets the minimum level of records sent to CloudWatch.
+// The default is slog.LevelDebug.
+func WithLevel(level slog.Level) HandlerOption {
+	return func(h *CloudWatchLogHandler) {
+		h.level.Set(level)
+	}
+}
+
+// WithContextExtractor registers a function that runs on every record and
+// derives attributes from the context passed to the logger, for example the
+// trace and span IDs of the current span. The attributes are added at the top
+// level of the log, outside of any group.
+func WithContextExtractor(extract func(ctx context.Context) []slog.Attr) HandlerOption {
+	return func(h *CloudWatchLogHandler) {
+		h.extractors = append(h.extractors, extract)
+	}
 }
 
 // NewCloudWatchLogHandler creates a new CloudWatchLogHandler.
-func NewCloudWatchLogHandler(client *CloudwatchClient) *CloudWatchLogHandler {
-	return &CloudWatchLogHandler{client: client}
+func NewCloudWatchLogHandler(client *CloudwatchClient, opts ...HandlerOption) *CloudWatchLogHandler {
+	return NewHandler(client, opts...)
 }
 
-// NewCloudwatchClient initializes a CloudwatchClient with user-provided AWS credentials
-// and creates a log stream. If the log group doesn't exist, it will create it.
-func NewCloudwatchClient(accessKey, secretAccessKey, logGroup, region string) (*CloudwatchClient, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretAccessKey, "")),
-	)
+// NewHandler creates a handler that sends records to any LogSink.
+func NewHandler(sink LogSink, opts ...HandlerOption) *CloudWatchLogHandler {
+	h := &CloudWatchLogHandler{
+		sink:  sink,
+		level: new(slog.LevelVar),
+	}
+	h.level.Set(slog.LevelDebug)
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// NewClient initializes a CloudwatchClient for logGroup configured by opts and
+// creates a log stream. If the log group doesn't exist, it will create it.
+// Unless WithStaticCredentials or WithSharedConfigProfile is given, credentials
+// are resolved through the default AWS credential chain. Log events are buffered and sent in batches
+// by a background goroutine.
+func NewClient(logGroup string, opts ...Option) (*CloudwatchClient, error) {
+	return newClient(context.Background(), logGroup, opts)
+}
+
+// newClient loads the AWS config and creates the client, giving up once ctx
+// is done.
+func newClient(ctx context.Context, logGroup string, opts []Option) (*CloudwatchClient, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var loadOpts []func(*config.LoadOptions) error
+	if o.region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(o.region))
+	}
+	if o.profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(o.profile))
+	}
+	if o.credentials != nil {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(o.credentials))
+	}
+	if o.httpClient != nil {
+		loadOpts = append(loadOpts, config.WithHTTPClient(o.httpClient))
+	}
+
+	if err := validateLogGroupName(logGroup); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("could not load AWS config: %w", err)
+		return nil, setupError(ctx, fmt.Errorf("could not load AWS config: %w", err))
+	}
+	if cfg.Region == "" {
+		cfg.Region = imdsRegion(ctx, loadOpts)
 	}
 
-	cwClient := cloudwatchlogs.NewFromConfig(cfg)
+	return newCloudwatchClientFromConfig(ctx, cfg, logGroup, o)
+}
 
-	// Explicitly check if the exact log group exists
-	exists := false
-	output, err := cwClient.DescribeLogGroups(context.TODO(), &cloudwatchlogs.DescribeLogGroupsInput{
-		LogGroupNamePattern: aws.String(logGroup),
-	})
+// imdsRegion returns the region of the EC2 instance the program runs on, or
+// an empty string when it isn't running on EC2.
+func imdsRegion(ctx context.Context, loadOpts []func(*config.LoadOptions) error) string {
+	ctx, cancel := context.WithTimeout(ctx, imdsRegionTimeout)
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx, append(loadOpts, config.WithEC2IMDSRegion())...)
 	if err != nil {
-		log.Printf("Error checking log group existence: %v", err)
-	} else {
-		for _, group := range output.LogGroups {
-			if aws.ToString(group.LogGroupName) == logGroup {
-				exists = true
-				break
-			}
+		return ""
+	}
+	return cfg.Region
+}
+
+// NewCloudwatchClientFromConfig initializes a CloudwatchClient on top of an
+// existing aws.Config, reusing its HTTP client, endpoint, retryer and
+// credentials, for example to share them with other AWS clients or to point
+// the client at LocalStack. WithRegion and WithStaticCredentials, if given,
+// override the region and credentials of cfg.
+func NewCloudwatchClientFromConfig(cfg aws.Config, logGroup string, opts ...Option) (*CloudwatchClient, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := validateLogGroupName(logGroup); err != nil {
+		return nil, err
+	}
+
+	if o.region != "" {
+		cfg.Region = o.region
+	}
+	if o.credentials != nil {
+		cfg.Credentials = aws.NewCredentialsCache(o.credentials)
+	}
+
+	return newCloudwatchClientFromConfig(context.Background(), cfg, logGroup, o)
+}
+
+// newCloudwatchClientFromConfig assumes the configured role, if any, and
+// creates the client from cfg.
+func newCloudwatchClientFromConfig(ctx context.Context, cfg aws.Config, logGroup string, o options) (*CloudwatchClient, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("no AWS region configured: pass a region or WithRegion, or set AWS_REGION")
+	}
+	o.region = cfg.Region
+	if o.httpClient != nil {
+		cfg.HTTPClient = o.httpClient
+	}
+	if o.endpoint != "" {
+		if u, err := url.Parse(o.endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q: must be an absolute URL such as http://localhost:4566", o.endpoint)
 		}
 	}
 
-	// If the log group doesn't exist, create it
-	if !exists {
-		log.Printf("Log group %s does not exist, creating...", logGroup)
-		_, err = cwClient.CreateLogGroup(context.TODO(), &cloudwatchlogs.CreateLogGroupInput{
-			LogGroupName: aws.String(logGroup),
+	if role := o.assumeRole; role != nil {
+		if role.roleARN == "" {
+			return nil, fmt.Errorf("invalid assume role: role ARN must not be empty")
+		}
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role.roleARN, func(ao *stscreds.AssumeRoleOptions) {
+			if role.sessionName != "" {
+				ao.RoleSessionName = role.sessionName
+			}
+			if role.externalID != "" {
+				ao.ExternalID = aws.String(role.externalID)
+			}
 		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	cwClient := cloudwatchlogs.NewFromConfig(cfg, func(co *cloudwatchlogs.Options) {
+		if o.endpoint != "" {
+			co.BaseEndpoint = aws.String(o.endpoint)
+		}
+	})
+	return newCloudwatchClient(ctx, cwClient, logGroup, o)
+}
+
+// NewCloudwatchClient initializes a CloudwatchClient with user-provided AWS credentials
+// and creates a log stream. If the log group doesn't exist, it will create it.
+// It is equivalent to NewClient with WithRegion and WithStaticCredentials.
+// If both keys are empty the default AWS credential chain is used instead, and
+// if region is empty it is resolved like the AWS SDK does, from AWS_REGION,
+// AWS_DEFAULT_REGION, the shared config or the EC2 instance metadata.
+func NewCloudwatchClient(accessKey, secretAccessKey, logGroup, region string, opts ...Option) (*CloudwatchClient, error) {
+	return NewCloudwatchClientCtx(context.Background(), accessKey, secretAccessKey, logGroup, region, opts...)
+}
+
+// NewCloudwatchClientCtx is like NewCloudwatchClient but gives up once ctx is
+// done, so a deadline bounds the whole setup: loading the AWS config, creating
+// the log group and streams, and the waits and retries in between. If ctx ends
+// first, the returned error wraps ctx.Err(). ctx only governs the setup; the
+// client keeps running after it is cancelled.
+func NewCloudwatchClientCtx(ctx context.Context, accessKey, secretAccessKey, logGroup, region string, opts ...Option) (*CloudwatchClient, error) {
+	if (accessKey == "") != (secretAccessKey == "") {
+		return nil, fmt.Errorf("invalid credentials: accessKey and secretAccessKey must both be set or both be empty")
+	}
+
+	var base []Option
+	if region != "" {
+		base = append(base, WithRegion(region))
+	}
+	if accessKey != "" {
+		base = append(base, WithStaticCredentials(accessKey, secretAccessKey))
+	}
+	return newClient(ctx, logGroup, append(base, opts...))
+}
+
+// NewCloudwatchClientFromEnv initializes a CloudwatchClient using the default AWS
+// credential chain (environment variables, shared config, web identity and
+// instance or task roles) and creates a log stream. If the log group doesn't
+// exist, it will create it. It is equivalent to NewClient with WithRegion. An
+// empty region is resolved from the environment, as with NewCloudwatchClient.
+func NewCloudwatchClientFromEnv(logGroup, region string, opts ...Option) (*CloudwatchClient, error) {
+	if region != "" {
+		opts = append([]Option{WithRegion(region)}, opts...)
+	}
+	return NewClient(logGroup, opts...)
+}
+
+// NewCloudwatchClientWithAPI initializes a CloudwatchClient on top of an existing
+// CloudwatchAPI implementation, such as a fake used in tests. It ensures the log
+// group exists, creates a log stream and starts the background flusher.
+// Options that configure the AWS connection, such as WithRegion, are ignored.
+func NewCloudwatchClientWithAPI(cwClient CloudwatchAPI, logGroup string, opts ...Option) (*CloudwatchClient, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return newCloudwatchClient(context.Background(), cwClient, logGroup, o)
+}
+
+// newCloudwatchClient ensures the log group exists, creates a log stream and
+// starts the background flusher, giving up once ctx is done.
+func newCloudwatchClient(ctx context.Context, cwClient CloudwatchAPI, logGroup string, o options) (*CloudwatchClient, error) {
+	if err := validateLogGroupName(logGroup); err != nil {
+		return nil, err
+	}
+	if o.retentionDays != nil && !validRetention(*o.retentionDays) {
+		return nil, fmt.Errorf("invalid log group retention of %d days", *o.retentionDays)
+	}
+	if err := validateTags(o.tags); err != nil {
+		return nil, err
+	}
+	if o.logStream != "" {
+		if err := validateLogStreamName(o.logStream); err != nil {
+			return nil, err
+		}
+	}
+	if o.errorStream != "" {
+		if err := validateLogStreamName(o.errorStream); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.hostname {
+		o.defaultAttrs = hostnameAttrs(o)
+	}
+
+	if err := ensureLogGroup(ctx, cwClient, logGroup, o); err != nil {
+		return nil, setupError(ctx, err)
+	}
+
+	logStream := o.logStream
+	if o.dailyRotation {
+		logStream = dailyStreamName(o, o.clock.Now())
+	} else if logStream == "" {
+		// Generate a unique log stream name
+		logStream = fmt.Sprintf("slogcloud-stream-%s-%s",
+			o.clock.Now().Format("20060102T150405"),
+			uuid.New().String(),
+		)
+	}
+
+	streams := make([]*streamState, o.streamCount)
+	for i, name := range streamNames(logStream, o.streamCount) {
+		sequenceToken, err := createLogStream(ctx, cwClient, logGroup, name, o)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create log group: %w", err)
+			return nil, setupError(ctx, err)
 		}
-		log.Printf("Log group %s created successfully", logGroup)
+		streams[i] = &streamState{group: logGroup, name: name, sequenceToken: sequenceToken}
+	}
 
-		// Add a delay after creating the log group
-		time.Sleep(3 * time.Second)
-	} else {
-		log.Printf("Log group %s already exists", logGroup)
+	// The error stream follows the round-robin streams
+	if o.errorStream != "" {
+		for _, stream := range streams {
+			if stream.name == o.errorStream {
+				return nil, fmt.Errorf("error stream %s is also a log stream of the client", o.errorStream)
+			}
+		}
+		sequenceToken, err := createLogStream(ctx, cwClient, logGroup, o.errorStream, o)
+		if err != nil {
+			return nil, setupError(ctx, err)
+		}
+		streams = append(streams, &streamState{group: logGroup, name: o.errorStream, sequenceToken: sequenceToken})
 	}
 
-	// Generate a unique log stream name
-	logStream := fmt.Sprintf("slogcloud-stream-%s-%s",
-		time.Now().Format("20060102T150405"),
-		uuid.New().String(),
-	)
+	var disk *diskBuffer
+	if o.diskDir != "" {
+		var err error
+		if disk, err = openDiskBuffer(o.diskDir, o.diskMaxBytes); err != nil {
+			return nil, err
+		}
+	}
 
-	log.Printf("Creating log stream %s in group %s", logStream, logGroup)
+	cw := &CloudwatchClient{
+		disk:       disk,
+		client:     cwClient,
+		logStream:  streams[0].name,
+		logGroup:   logGroup,
+		opts:       o,
+		streams:    streams,
+		queue:      make(chan queuedEvent, o.queueSize),
+		flushReqs:  make(chan flushRequest),
+		switchReqs: make(chan switchRequest),
+		rotated:    make(chan rotation),
+		closing:    make(chan struct{}),
+		stopped:    make(chan struct{}),
+		errs:       make(chan failedRecord, errorQueueSize),
+		errorsDone: make(chan struct{}),
+	}
+	if o.dailyRotation {
+		cw.nextRotation = nextMidnight(o.clock.Now(), o.rotationLocation).UnixMilli()
+	}
+	go cw.run()
+	go cw.reportErrors()
 
-	// Create the log stream with retries
-	maxRetries := 3
-	var lastErr error
-	for i := 0; i < maxRetries; i++ {
-		_, err = cwClient.CreateLogStream(context.TODO(), &cloudwatchlogs.CreateLogStreamInput{
-			LogGroupName:  aws.String(logGroup),
-			LogStreamName: aws.String(logStream),
-		})
-		if err == nil {
-			log.Printf("Log stream created successfully")
-			break
+	if o.startupPing {
+		if err := cw.ping(ctx); err != nil {
+			_ = cw.Close()
+			return nil, setupError(ctx, fmt.Errorf("startup log could not be sent: %w", err))
 		}
-		lastErr = err
-		log.Printf("Attempt %d: Failed to create log stream: %v", i+1, err)
-		time.Sleep(2 * time.Second)
 	}
 
-	if lastErr != nil {
-		return nil, fmt.Errorf("failed to create CloudWatch log stream after %d attempts: %w", maxRetries, lastErr)
+	return cw, nil
+}
+
+// setupError returns err, or an error wrapping the context's error if ctx
+// ended while the client was being created, so callers can tell a timeout
+// apart from a failed AWS call.
+func setupError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("CloudWatch client setup interrupted: %w (%v)", ctxErr, err)
 	}
+	return err
+}
+
+// ping sends a startup log and waits until CloudWatch has accepted it.
+func (cw *CloudwatchClient) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, startupPingTimeout)
+	defer cancel()
 
-	return &CloudwatchClient{
-		client:    cwClient,
-		logStream: logStream,
-		logGroup:  logGroup,
-	}, nil
+	r := slog.NewRecord(cw.opts.clock.Now(), slog.LevelInfo, "slogcloud initialized", 0)
+	r.AddAttrs(slog.String("log_group", cw.logGroup), slog.String("log_stream", cw.currentLogStream()))
+	if cw.opts.region != "" {
+		r.AddAttrs(slog.String("region", cw.opts.region))
+	}
+	if err := cw.EmitLogCtx(ctx, r); err != nil {
+		return err
+	}
+	return cw.Flush(ctx)
 }
 
 //////////////////////////////
 ///// METHODS FOR CLIENT /////
 //////////////////////////////
 
-// EmitLog sends log records to AWS CloudWatch.
+// LogStreams returns the names of the log streams the client writes to.
+func (cw *CloudwatchClient) LogStreams() []string {
+	cw.streamsMu.RLock()
+	defer cw.streamsMu.RUnlock()
+
+	names := make([]string, len(cw.streams))
+	for i, stream := range cw.streams {
+		names[i] = stream.name
+	}
+	return names
+}
+
+// EmitLog queues a log record to be sent to AWS CloudWatch with the next batch.
 func (cw *CloudwatchClient) EmitLog(r slog.Record) error {
-	message := r.Message
+	return cw.EmitLogCtx(context.Background(), r)
+}
 
-	logEntry := map[string]interface{}{
-		"message": message,
+// EmitLogCtx is like EmitLog but gives up with the context's error if ctx is
+// cancelled while waiting for room in the queue under OverflowBlock. A record
+// logged with an already cancelled ctx, such as at the end of a request, is
+// still queued if there is room. Records are sent in batches shared with
+// other callers, so ctx does not govern the PutLogEvents call itself; use
+// Flush for that.
+func (cw *CloudwatchClient) EmitLogCtx(ctx context.Context, r slog.Record) error {
+	var route *routeKey
+	if cw.opts.router != nil {
+		route = cw.route(r)
+	}
+	if !cw.groupEnabled(route, r.Level) {
+		return nil
 	}
 
-	r.Attrs(func(a slog.Attr) bool {
-		val := a.Value.Any()
+	message := cw.formatRecord(r)
+	if len(message) > maxEventMessage {
+		prefix := truncateString(cw.opts.redaction.redact(r).Message, 64)
+		err := fmt.Errorf("%w: %d bytes exceed the limit of %d for log %q", ErrMessageTooLarge, len(message), maxEventMessage, prefix)
+		cw.opts.metrics.LogsFailed(1)
+		cw.reportError(err, r.Clone())
+		return err
+	}
 
-		if errValue, ok := val.(error); ok {
-			logEntry[a.Key] = errValue.Error()
-		} else {
-			logEntry[a.Key] = val
-		}
+	event := types.InputLogEvent{
+		Message:   aws.String(string(message)),
+		Timestamp: aws.Int64(cw.eventTimestamp(r)),
+	}
+
+	qe := queuedEvent{event: event, record: r.Clone(), route: route}
+	if qe.route == nil {
+		qe.stream = cw.pickStream(r.Level)
+	}
+	return cw.submit(ctx, qe)
+}
+
+// submit queues qe and records the outcome in the metrics, reporting a
+// dropped or failed event to the error handler.
+func (cw *CloudwatchClient) submit(ctx context.Context, qe queuedEvent) error {
+	err := cw.enqueue(ctx, qe)
+	switch {
+	case err == nil:
+		cw.opts.metrics.LogEmitted()
+		cw.trackQueue()
+	case errors.Is(err, ErrQueueFull):
+		cw.opts.metrics.LogDropped(DropQueueFull)
+		cw.reportError(err, qe.record)
+	default:
+		cw.opts.metrics.LogsFailed(1)
+		cw.reportError(err, qe.record)
+	}
+	return err
+}
+
+// withDefaultAttrs returns r with the client's default attributes added before
+// its own, so the record's attributes win on conflicting keys.
+func (cw *CloudwatchClient) withDefaultAttrs(r slog.Record) slog.Record {
+	if len(cw.opts.defaultAttrs) == 0 {
+		return r
+	}
+
+	merged := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	merged.AddAttrs(cw.opts.defaultAttrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		merged.AddAttrs(a)
 		return true
 	})
+	return merged
+}
+
+// withSequence adds the next sequence number of the client under SeqKey when
+// WithSequenceNumbers is set. It is added last, so it replaces an attribute
+// of the record with the same key.
+func (cw *CloudwatchClient) withSequence(r slog.Record) slog.Record {
+	if !cw.opts.seqNumbers {
+		return r
+	}
 
-	logEntryJson, _ := json.Marshal(logEntry)
+	r = r.Clone()
+	r.AddAttrs(slog.Uint64(SeqKey, cw.seq.Add(1)))
+	return r
+}
 
-	// Prepare the log input for CloudWatch
-	input := &cloudwatchlogs.PutLogEventsInput{
-		LogGroupName:  aws.String(cw.logGroup),
-		LogStreamName: aws.String(cw.logStream),
-		LogEvents: []types.InputLogEvent{
-			{
-				Message:   aws.String(string(logEntryJson)),
-				Timestamp: aws.Int64(time.Now().UnixNano() / int64(time.Millisecond)),
-			},
-		},
+// withSource adds the location r was logged at under slog.SourceKey, as
+// slog's AddSource does, when WithSource is set. It must run before r is
+// queued, while r.PC can still be resolved.
+func (cw *CloudwatchClient) withSource(r slog.Record) slog.Record {
+	if !cw.opts.addSource || r.PC == 0 {
+		return r
 	}
 
-	_, err := cw.client.PutLogEvents(context.TODO(), input)
-	if err != nil {
-		return fmt.Errorf("failed to send log to CloudWatch: %w", err)
+	frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+	r = r.Clone()
+	r.AddAttrs(slog.Group(slog.SourceKey,
+		slog.String("function", frame.Function),
+		slog.String("file", frame.File),
+		slog.Int("line", frame.Line),
+	))
+	return r
+}
+
+// eventTimestamp returns the time r was logged in milliseconds since the epoch.
+// Records without a time, or with a time CloudWatch would reject, are stamped
+// with the current time instead.
+func (cw *CloudwatchClient) eventTimestamp(r slog.Record) int64 {
+	now := cw.opts.clock.Now()
+	if r.Time.IsZero() {
+		return now.UnixMilli()
 	}
 
-	return nil
+	if r.Time.Before(now.Add(-maxEventAge)) || r.Time.After(now.Add(maxEventFuture)) {
+		cw.opts.debugf("Log time %s is outside the range CloudWatch accepts, using the current time", r.Time.Format(time.RFC3339))
+		return now.UnixMilli()
+	}
+
+	return r.Time.UnixMilli()
+}
+
+// buildLogEntry collects the message, level and attributes of r into the map
+// that is marshaled to JSON. Errors are serialized according to ef.
+func buildLogEntry(r slog.Record, levelKey string, ef errorFormat) map[string]interface{} {
+	message := r.Message
+
+	logEntry := newLogEntry()
+	logEntry["message"] = message
+	logEntry[levelKey] = levelString(r.Level)
+
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(logEntry, a, ef)
+		return true
+	})
+
+	return logEntry
+}
+
+// addAttr adds a to entry. Group attributes are nested as objects under their
+// key, merging with any group of the same name already present.
+func addAttr(entry map[string]interface{}, a slog.Attr, ef errorFormat) {
+	// LogValuers, such as lazily computed or self-redacting values, are
+	// replaced by the value they log as
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+
+		// Groups without a key are inlined, as slog's built-in handlers do
+		group := entry
+		if a.Key != "" {
+			nested, ok := entry[a.Key].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{}, len(attrs))
+				entry[a.Key] = nested
+			}
+			group = nested
+		}
+
+		for _, ga := range attrs {
+			addAttr(group, ga, ef)
+		}
+		return
+	}
+
+	val := a.Value.Any()
+
+	if errValue, ok := val.(error); ok {
+		entry[a.Key] = formatError(errValue, ef)
+	} else {
+		entry[a.Key] = val
+	}
 }
 
+// GetLogger returns a Logger that sends logs to CloudWatch when env is PROD,
+// to both CloudWatch and stdout when env is STAGING, and writes to stdout
+// otherwise. If accessKey and secretAccessKey are both empty, credentials are
+// resolved through the default AWS credential chain.
+//
+// In PROD and STAGING it also installs the logger as slog's default with
+// slog.SetDefault, so slog.Info and the like go to CloudWatch for the whole
+// process. Use NewLogger to get the same logger without that side effect.
+// New code should prefer NewSlogLogger, which returns a standard *slog.Logger,
+// or NewSlogHandler.
 func GetLogger(env, accessKey, secretAccessKey, logGroup, region string) (Logger, error) {
-	if env == PROD {
+	return NewLogger(env, accessKey, secretAccessKey, logGroup, region, WithSetDefault())
+}
+
+// LoggerOption configures the logger returned by NewLogger.
+type LoggerOption func(*loggerOptions)
+
+// loggerOptions holds the settings of NewLogger.
+type loggerOptions struct {
+	setDefault bool
+}
+
+// WithSetDefault makes NewLogger install the logger as slog's default with
+// slog.SetDefault, as GetLogger does, in PROD and STAGING.
+func WithSetDefault() LoggerOption {
+	return func(o *loggerOptions) {
+		o.setDefault = true
+	}
+}
+
+// NewLogger returns the same Logger as GetLogger, but leaves slog's default
+// logger alone unless WithSetDefault is given.
+func NewLogger(env, accessKey, secretAccessKey, logGroup, region string, opts ...LoggerOption) (Logger, error) {
+	var o loggerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch env {
+	case PROD:
 		// In production, log to CloudWatch using slog
-		cwClient, err := NewCloudwatchClient(accessKey, secretAccessKey, logGroup, region)
+		cloudWatchHandler, err := newProdHandler(accessKey, secretAccessKey, logGroup, region)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create CloudWatch client: %w", err)
+			return nil, err
+		}
+		logger := newSlogLogger(cloudWatchHandler)
+		if o.setDefault {
+			slog.SetDefault(logger.logger)
 		}
 
-		cloudWatchHandler := NewCloudWatchLogHandler(cwClient)
-		slog.SetDefault(slog.New(cloudWatchHandler))
+		return logger, nil
+	case STAGING:
+		cloudWatchHandler, err := newProdHandler(accessKey, secretAccessKey, logGroup, region)
+		if err != nil {
+			return nil, err
+		}
+		multi := slog.New(NewMultiHandler(cloudWatchHandler, newConsoleHandler()))
+		logger := &SlogLogger{
+			fatalExit: &fatalExit{},
+			handler:   cloudWatchHandler,
+			logger:    multi,
+			unnamed:   multi,
+		}
+		if o.setDefault {
+			slog.SetDefault(logger.logger)
+		}
 
-		return &SlogLogger{handler: cloudWatchHandler}, nil
+		return logger, nil
 	}
 
 	// For non-production environments, log to standard output
-	return &StdLogger{}, nil
+	return NewStdLogger(), nil
+}
+
+// NewSlogLogger returns a *slog.Logger that sends logs to CloudWatch when env
+// is PROD, to both CloudWatch and stdout when env is STAGING, and writes text
+// to stdout otherwise, so the whole slog API (With, WithGroup, LogAttrs, ...)
+// is available. Credentials are resolved as in GetLogger. Call CloseLogger
+// before the program exits to flush pending logs.
+func NewSlogLogger(env, accessKey, secretAccessKey, logGroup, region string, opts ...HandlerOption) (*slog.Logger, error) {
+	handler, err := NewSlogHandler(env, accessKey, secretAccessKey, logGroup, region, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(handler), nil
+}
+
+// NewSlogHandler returns the handler of the logger NewSlogLogger would return,
+// to be composed with other handlers, for example wrapped by a sampling or
+// filtering middleware, before building a logger. It doesn't change slog's
+// default logger. Call CloseHandler before the program exits to flush pending
+// logs.
+func NewSlogHandler(env, accessKey, secretAccessKey, logGroup, region string, opts ...HandlerOption) (slog.Handler, error) {
+	switch env {
+	case PROD:
+		cloudWatchHandler, err := newProdHandler(accessKey, secretAccessKey, logGroup, region, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return cloudWatchHandler, nil
+	case STAGING:
+		cloudWatchHandler, err := newProdHandler(accessKey, secretAccessKey, logGroup, region, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return NewMultiHandler(cloudWatchHandler, newConsoleHandler()), nil
+	}
+
+	return newConsoleHandler(), nil
+}
+
+// CloseLogger flushes pending logs and releases the sink of a logger created
+// by NewSlogLogger. It is a no-op for loggers that don't buffer.
+func CloseLogger(logger *slog.Logger) error {
+	return CloseHandler(logger.Handler())
+}
+
+// CloseHandler flushes pending logs and releases the sink of a handler created
+// by NewSlogHandler. It is a no-op for handlers that don't buffer.
+func CloseHandler(handler slog.Handler) error {
+	if c, ok := handler.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// newConsoleHandler returns the handler used to log to stdout.
+func newConsoleHandler() slog.Handler {
+	return NewConsoleHandler(os.Stdout)
+}
+
+// newProdHandler creates a CloudWatch client and a handler writing to it.
+func newProdHandler(accessKey, secretAccessKey, logGroup, region string, opts ...HandlerOption) (*CloudWatchLogHandler, error) {
+	var cwClient *CloudwatchClient
+	var err error
+	if accessKey == "" && secretAccessKey == "" {
+		cwClient, err = NewCloudwatchClientFromEnv(logGroup, region)
+	} else {
+		cwClient, err = NewCloudwatchClient(accessKey, secretAccessKey, logGroup, region)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CloudWatch client: %w", err)
+	}
+
+	return NewCloudWatchLogHandler(cwClient, opts...), nil
 }
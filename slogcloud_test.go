@@ -0,0 +1,293 @@
+package slogcloud
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestHandlerSendsJSON(t *testing.T) {
+	cw, fake := newTestClient(t)
+	logger := slog.New(NewCloudWatchLogHandler(cw))
+
+	logger.With("service", "checkout").WithGroup("req").Info("Order placed", "id", 42, "paid", true)
+	logger.Error("Save failed", "error", errors.New("disk full"))
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	want := []map[string]any{
+		{"level": "INFO", "message": "Order placed", "service": "checkout", "req": map[string]any{"id": float64(42), "paid": true}},
+		{"level": "ERROR", "message": "Save failed", "error": "disk full"},
+	}
+	if got := fake.entries(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+
+	calls := fake.putCalls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d PutLogEvents calls, want 1", len(calls))
+	}
+	if got := *calls[0].LogGroupName; got != "test" {
+		t.Errorf("sent to log group %q, want %q", got, "test")
+	}
+	if got := *calls[0].LogStreamName; got != cw.logStream {
+		t.Errorf("sent to log stream %q, want %q", got, cw.logStream)
+	}
+}
+
+func TestEmitLogCtxCancelled(t *testing.T) {
+	cw, fake := newTestClient(t, WithQueueSize(1), WithBatchSize(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := cw.EmitLogCtx(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "request done", 0)); err != nil {
+		t.Fatalf("EmitLogCtx with a cancelled ctx and room in the queue: %v", err)
+	}
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := fake.messages(); len(got) != 1 {
+		t.Fatalf("sent %q, want the log made with the cancelled ctx", got)
+	}
+
+	// Hold the batch being sent so that the queue stays full
+	release := make(chan struct{})
+	defer close(release)
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		<-release
+		return &cloudwatchlogs.PutLogEventsOutput{}, nil
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for cw.QueueLen() < 1 && time.Now().Before(deadline) {
+		_ = cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "filler", 0))
+	}
+	if cw.QueueLen() < 1 {
+		t.Fatal("queue did not fill up")
+	}
+
+	if err := cw.EmitLogCtx(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "blocked", 0)); !errors.Is(err, context.Canceled) {
+		t.Errorf("EmitLogCtx on a full queue = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDefaultClientIsSilent(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	log.SetOutput(w)
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(os.Stderr)
+	}()
+
+	cw, fake := newTestClient(t)
+	fake.setPut(func(*cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		return nil, errors.New("unavailable")
+	})
+	logger := slog.New(NewCloudWatchLogHandler(cw))
+	logger.Info("starting", "port", 8080)
+	logger.Error("failed", "error", errors.New("boom"))
+	_ = cw.Close()
+
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) > 0 {
+		t.Errorf("client wrote %q", out)
+	}
+}
+
+func TestBatchSortedByTimestamp(t *testing.T) {
+	cw, fake := newTestClient(t, WithFlushInterval(time.Hour))
+	fake.setPut(func(in *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+		if !slices.IsSortedFunc(in.LogEvents, func(a, b types.InputLogEvent) int {
+			return cmp.Compare(*a.Timestamp, *b.Timestamp)
+		}) {
+			return nil, &types.InvalidParameterException{Message: aws.String("log events not in chronological order")}
+		}
+		return &cloudwatchlogs.PutLogEventsOutput{}, nil
+	})
+
+	now := time.Now()
+	for _, offset := range []int{3, 1, 4, 0, 2} {
+		r := slog.NewRecord(now.Add(time.Duration(offset)*time.Second), slog.LevelInfo, strconv.Itoa(offset), 0)
+		if err := cw.EmitLog(r); err != nil {
+			t.Fatalf("EmitLog: %v", err)
+		}
+	}
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var got []string
+	for _, entry := range fake.entries(t) {
+		got = append(got, entry["message"].(string))
+	}
+	if want := []string{"0", "1", "2", "3", "4"}; !slices.Equal(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+// secret redacts itself when logged.
+type secret string
+
+func (secret) LogValue() slog.Value {
+	return slog.StringValue("***")
+}
+
+// account logs as a group of its fields.
+type account struct {
+	id   int
+	plan string
+}
+
+func (a account) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", a.id), slog.String("plan", a.plan))
+}
+
+func TestHandlerResolvesLogValuer(t *testing.T) {
+	cw, fake := newTestClient(t)
+	logger := slog.New(NewCloudWatchLogHandler(cw))
+
+	logger.With("api_key", secret("sk-live-123")).Info("charged",
+		"account", account{id: 7, plan: "pro"},
+		slog.Group("card", "number", secret("4111111111111111")),
+	)
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	want := []map[string]any{{
+		"level": "INFO", "message": "charged", "api_key": "***",
+		"account": map[string]any{"id": float64(7), "plan": "pro"},
+		"card":    map[string]any{"number": "***"},
+	}}
+	if got := fake.entries(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+// alwaysEnabled wraps a handler without asking it whether a level is enabled,
+// as some middleware handlers do.
+type alwaysEnabled struct {
+	slog.Handler
+}
+
+func (alwaysEnabled) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h alwaysEnabled) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return alwaysEnabled{h.Handler.WithAttrs(attrs)}
+}
+
+func (h alwaysEnabled) WithGroup(name string) slog.Handler {
+	return alwaysEnabled{h.Handler.WithGroup(name)}
+}
+
+// countingSink records the messages of the records it is given.
+type countingSink struct {
+	emitted []string
+}
+
+func (s *countingSink) Emit(_ context.Context, r slog.Record) error {
+	s.emitted = append(s.emitted, r.Message)
+	return nil
+}
+
+func TestWrappedHandlerSkipsDisabledLevels(t *testing.T) {
+	sink := &countingSink{}
+	logger := slog.New(alwaysEnabled{NewHandler(sink, WithLevel(slog.LevelWarn))})
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.With("k", "v").WithGroup("g").Info("grouped info")
+	logger.Warn("warn")
+
+	if want := []string{"warn"}; !slices.Equal(sink.emitted, want) {
+		t.Errorf("emitted %q, want %q", sink.emitted, want)
+	}
+}
+
+func TestEmitLogAfterClose(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropNewest, OverflowDropOldest} {
+		cw, _ := newTestClient(t, WithOverflowPolicy(policy))
+		if err := cw.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		for range 100 {
+			if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)); !errors.Is(err, ErrClientClosed) {
+				t.Fatalf("EmitLog after Close with policy %d = %v, want %v", policy, err, ErrClientClosed)
+			}
+		}
+	}
+}
+
+// leastPrivilegeCloudwatch is a fakeCloudwatch for a role that may only
+// create log streams and send events; every log group call is denied.
+type leastPrivilegeCloudwatch struct {
+	*fakeCloudwatch
+}
+
+var errAccessDenied = errors.New("AccessDeniedException")
+
+func (leastPrivilegeCloudwatch) DescribeLogGroups(context.Context, *cloudwatchlogs.DescribeLogGroupsInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	return nil, errAccessDenied
+}
+
+func (leastPrivilegeCloudwatch) CreateLogGroup(context.Context, *cloudwatchlogs.CreateLogGroupInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	return nil, errAccessDenied
+}
+
+func (leastPrivilegeCloudwatch) TagLogGroup(context.Context, *cloudwatchlogs.TagLogGroupInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagLogGroupOutput, error) {
+	return nil, errAccessDenied
+}
+
+func (leastPrivilegeCloudwatch) AssociateKmsKey(context.Context, *cloudwatchlogs.AssociateKmsKeyInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.AssociateKmsKeyOutput, error) {
+	return nil, errAccessDenied
+}
+
+func (leastPrivilegeCloudwatch) PutRetentionPolicy(context.Context, *cloudwatchlogs.PutRetentionPolicyInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	return nil, errAccessDenied
+}
+
+func TestCreateLogGroupDisabledLeavesGroupAlone(t *testing.T) {
+	fake := leastPrivilegeCloudwatch{&fakeCloudwatch{}}
+	cw, err := NewCloudwatchClientWithAPI(fake, "test",
+		WithCreateLogGroup(false),
+		WithTags(map[string]string{"team": "payments"}),
+		WithKMSKey("arn:aws:kms:us-west-2:123456789012:key/1234"),
+		WithRetention(7),
+	)
+	if err != nil {
+		t.Fatalf("NewCloudwatchClientWithAPI: %v", err)
+	}
+	defer cw.Close()
+
+	if err := cw.EmitLog(slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0)); err != nil {
+		t.Fatalf("EmitLog: %v", err)
+	}
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := fake.messages(); len(got) != 1 {
+		t.Errorf("sent %q, want one log", got)
+	}
+}
@@ -0,0 +1,7 @@
+module github.com/melkeydev/slog-cloud/slogcloudotel
+
+go 1.23.2
+
+require go.opentelemetry.io/otel/trace v1.31.0
+
+require go.opentelemetry.io/otel v1.31.0 // indirect
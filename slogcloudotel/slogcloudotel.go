@@ -0,0 +1,28 @@
+// Package slogcloudotel connects OpenTelemetry tracing to slogcloud so each
+// log sent to CloudWatch carries the trace and span of the active span.
+//
+// It is a module of its own so that depending on slogcloud does not pull in
+// the OpenTelemetry dependencies.
+package slogcloudotel
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceAttrs returns the trace_id and span_id of the span in ctx. It returns
+// nil when ctx holds no valid span. Register it with
+// slogcloud.WithContextExtractor(slogcloudotel.TraceAttrs).
+func TraceAttrs(ctx context.Context) []slog.Attr {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return nil
+	}
+
+	return []slog.Attr{
+		slog.String("trace_id", spanCtx.TraceID().String()),
+		slog.String("span_id", spanCtx.SpanID().String()),
+	}
+}
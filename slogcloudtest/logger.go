@@ -0,0 +1,147 @@
+package slogcloudtest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	slogcloud "github.com/melkeydev/slog-cloud"
+)
+
+// TestLogger is a slogcloud.Logger that writes to a test's log, so the logs
+// of the code under test appear with the test's output, with the file and
+// line of the call that logged them. Debug, Info and Warn call tb.Log, Error
+// calls tb.Error, failing the test, and Fatal calls tb.Fatal, stopping it.
+//
+// Like tb.Log, it must not be used after the test has finished.
+type TestLogger struct {
+	tb   testing.TB
+	args []any
+}
+
+// NewTestLogger returns a TestLogger writing to tb.
+func NewTestLogger(tb testing.TB) *TestLogger {
+	return &TestLogger{tb: tb}
+}
+
+// Debug logs a debug message with tb.Log.
+func (l *TestLogger) Debug(msg string, args ...any) {
+	l.tb.Helper()
+	l.tb.Log(l.format(slog.LevelDebug, msg, nil, args))
+}
+
+// Info logs an info message with tb.Log.
+func (l *TestLogger) Info(msg string, args ...any) {
+	l.tb.Helper()
+	l.tb.Log(l.format(slog.LevelInfo, msg, nil, args))
+}
+
+// Warn logs a warning message with tb.Log.
+func (l *TestLogger) Warn(msg string, args ...any) {
+	l.tb.Helper()
+	l.tb.Log(l.format(slog.LevelWarn, msg, nil, args))
+}
+
+// Error logs an error message with tb.Error, which fails the test.
+func (l *TestLogger) Error(msg string, err error, args ...any) {
+	l.tb.Helper()
+	l.tb.Error(l.format(slog.LevelError, msg, err, args))
+}
+
+// Fatal logs a fatal error message with tb.Fatal, which fails and stops the
+// test instead of exiting the program.
+func (l *TestLogger) Fatal(msg string, err error, args ...any) {
+	l.tb.Helper()
+	l.tb.Fatal(l.format(slogcloud.LevelFatal, msg, err, args))
+}
+
+// DebugContext is like Debug. The context is ignored.
+func (l *TestLogger) DebugContext(_ context.Context, msg string, args ...any) {
+	l.tb.Helper()
+	l.Debug(msg, args...)
+}
+
+// InfoContext is like Info. The context is ignored.
+func (l *TestLogger) InfoContext(_ context.Context, msg string, args ...any) {
+	l.tb.Helper()
+	l.Info(msg, args...)
+}
+
+// WarnContext is like Warn. The context is ignored.
+func (l *TestLogger) WarnContext(_ context.Context, msg string, args ...any) {
+	l.tb.Helper()
+	l.Warn(msg, args...)
+}
+
+// ErrorContext is like Error. The context is ignored.
+func (l *TestLogger) ErrorContext(_ context.Context, msg string, err error, args ...any) {
+	l.tb.Helper()
+	l.Error(msg, err, args...)
+}
+
+// FatalContext is like Fatal. The context is ignored.
+func (l *TestLogger) FatalContext(_ context.Context, msg string, err error, args ...any) {
+	l.tb.Helper()
+	l.Fatal(msg, err, args...)
+}
+
+// With returns a logger that adds args to every log, writing to the same test.
+func (l *TestLogger) With(args ...any) slogcloud.Logger {
+	if len(args) == 0 {
+		return l
+	}
+	return &TestLogger{tb: l.tb, args: append(l.args[:len(l.args):len(l.args)], args...)}
+}
+
+// WithFields returns a logger that adds fields to every log.
+func (l *TestLogger) WithFields(fields slogcloud.Fields) slogcloud.Logger {
+	return l.With(fields)
+}
+
+// Close does nothing.
+func (l *TestLogger) Close() error {
+	return nil
+}
+
+// format renders a log as a logfmt line without a time, such as
+// `level=INFO msg="User logged in" user_id=42`.
+func (l *TestLogger) format(level slog.Level, msg string, err error, args []any) string {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.LevelKey && level == slogcloud.LevelFatal {
+				return slog.String(slog.LevelKey, "FATAL")
+			}
+			return a
+		},
+	})
+
+	r := slog.NewRecord(time.Time{}, level, msg, 0)
+	addArgs(&r, l.args)
+	if err != nil {
+		r.AddAttrs(slog.Any("error", err))
+	}
+	addArgs(&r, args)
+	_ = h.Handle(context.Background(), r)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// addArgs adds args to r, expanding Fields into their attributes.
+func addArgs(r *slog.Record, args []any) {
+	rest := make([]any, 0, len(args))
+	for _, arg := range args {
+		f, ok := arg.(slogcloud.Fields)
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		for _, a := range f.Attrs() {
+			rest = append(rest, a)
+		}
+	}
+	r.Add(rest...)
+}
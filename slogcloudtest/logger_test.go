@@ -0,0 +1,53 @@
+package slogcloudtest
+
+import (
+	"fmt"
+	"testing"
+
+	slogcloud "github.com/melkeydev/slog-cloud"
+)
+
+// recordingTB captures what a TestLogger writes with Log.
+type recordingTB struct {
+	testing.TB
+	logs []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Log(args ...any) {
+	tb.logs = append(tb.logs, fmt.Sprint(args...))
+}
+
+func TestTestLoggerArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l slogcloud.Logger)
+		want string
+	}{
+		{
+			name: "key/value pairs",
+			log:  func(l slogcloud.Logger) { l.Info("hi", "user_id", 42) },
+			want: "level=INFO msg=hi user_id=42",
+		},
+		{
+			name: "fields between pairs",
+			log:  func(l slogcloud.Logger) { l.Info("hi", "a", 1, slogcloud.Fields{"c": 3, "b": 2}, "d", 4) },
+			want: "level=INFO msg=hi a=1 b=2 c=3 d=4",
+		},
+		{
+			name: "with",
+			log:  func(l slogcloud.Logger) { l.With("k", "v").WithFields(slogcloud.Fields{"f": 1}).Warn("x", "n", 2) },
+			want: "level=WARN msg=x k=v f=1 n=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			tt.log(NewTestLogger(tb))
+			if len(tb.logs) != 1 || tb.logs[0] != tt.want {
+				t.Errorf("got %q, want %q", tb.logs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+// Package slogcloudtest provides an in-memory CloudWatch Logs fake, so code
+// that logs through slogcloud can be tested end to end without AWS.
+//
+// It is meant to be imported from tests only, so it is not part of
+// production builds.
+package slogcloudtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	slogcloud "github.com/melkeydev/slog-cloud"
+)
+
+// Event is a log event received by a FakeCloudwatch.
+type Event struct {
+	Group     string
+	Stream    string
+	Timestamp time.Time
+	// Message is the event as sent, usually a JSON document.
+	Message string
+}
+
+// logGroup is the state of a log group held by a FakeCloudwatch.
+type logGroup struct {
+	retention *int32
+	kmsKey    *string
+	tags      map[string]string
+	streams   map[string]bool
+}
+
+// FakeCloudwatch is an in-memory slogcloud.CloudwatchAPI. It keeps track of
+// the log groups and streams created through it, rejecting calls on ones that
+// don't exist as CloudWatch does, and records every event sent with
+// PutLogEvents. It is safe for concurrent use.
+//
+// The client sends events in batches, so call Flush or Close on it before
+// inspecting them.
+type FakeCloudwatch struct {
+	mu     sync.Mutex
+	groups map[string]*logGroup
+	events []Event
+	calls  int
+
+	// reject is reported by the next PutLogEvents call
+	reject *types.RejectedLogEventsInfo
+}
+
+// NewFakeCloudwatch creates a FakeCloudwatch without any log groups.
+func NewFakeCloudwatch() *FakeCloudwatch {
+	return &FakeCloudwatch{groups: make(map[string]*logGroup)}
+}
+
+// NewClient creates a slogcloud client for logGroup on top of a new
+// FakeCloudwatch, and closes it when the test ends.
+func NewClient(t testing.TB, logGroup string, opts ...slogcloud.Option) (*slogcloud.CloudwatchClient, *FakeCloudwatch) {
+	t.Helper()
+
+	fake := NewFakeCloudwatch()
+	cw, err := slogcloud.NewCloudwatchClientWithAPI(fake, logGroup, opts...)
+	if err != nil {
+		t.Fatalf("slogcloudtest: creating client: %v", err)
+	}
+	t.Cleanup(func() { _ = cw.Close() })
+	return cw, fake
+}
+
+// Events returns a copy of the events received so far, in the order they
+// were sent.
+func (f *FakeCloudwatch) Events() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events := make([]Event, len(f.events))
+	copy(events, f.events)
+	return events
+}
+
+// PutLogEventsCalls returns the number of successful PutLogEvents calls.
+func (f *FakeCloudwatch) PutLogEventsCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// Messages returns the "message" field of every event received so far. Events
+// that are not JSON objects are returned as they were sent.
+func (f *FakeCloudwatch) Messages() []string {
+	events := f.Events()
+	messages := make([]string, len(events))
+	for i, e := range events {
+		var doc map[string]any
+		if err := json.Unmarshal([]byte(e.Message), &doc); err == nil {
+			if msg, ok := doc["message"].(string); ok {
+				messages[i] = msg
+				continue
+			}
+		}
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+// LastJSON returns the last event received, decoded from JSON, or nil if
+// there is none or it is not a JSON object.
+func (f *FakeCloudwatch) LastJSON() map[string]any {
+	events := f.Events()
+	if len(events) == 0 {
+		return nil
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(events[len(events)-1].Message), &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// AssertContains fails the test unless an event received so far has value
+// under key. Keys of nested groups are written with dots, such as "user.id".
+// Values are compared by their JSON encoding, so AssertContains(t, "count", 3)
+// matches {"count":3}.
+func (f *FakeCloudwatch) AssertContains(t testing.TB, key string, value any) {
+	t.Helper()
+
+	want, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("slogcloudtest: cannot encode %v: %v", value, err)
+	}
+	var wantValue any
+	_ = json.Unmarshal(want, &wantValue)
+
+	events := f.Events()
+	for _, e := range events {
+		var doc map[string]any
+		if json.Unmarshal([]byte(e.Message), &doc) != nil {
+			continue
+		}
+		if got, ok := lookup(doc, key); ok && reflect.DeepEqual(got, wantValue) {
+			return
+		}
+	}
+	t.Errorf("slogcloudtest: none of the %d events has %s=%s", len(events), key, want)
+}
+
+// lookup returns the value under key in doc, following dots into nested
+// objects when doc has no such key itself.
+func lookup(doc map[string]any, key string) (any, bool) {
+	if v, ok := doc[key]; ok {
+		return v, true
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] != '.' {
+			continue
+		}
+		if nested, ok := doc[key[:i]].(map[string]any); ok {
+			if v, ok := lookup(nested, key[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Reset discards the events received so far. Log groups and streams are kept.
+func (f *FakeCloudwatch) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = nil
+	f.calls = 0
+}
+
+// group returns the state of the log group called name, or a
+// ResourceNotFoundException if there is none.
+func (f *FakeCloudwatch) group(name *string) (*logGroup, error) {
+	g, ok := f.groups[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("log group %s does not exist", aws.ToString(name)))}
+	}
+	return g, nil
+}
+
+// PutLogEvents records the events.
+func (f *FakeCloudwatch) PutLogEvents(_ context.Context, in *cloudwatchlogs.PutLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	if !g.streams[aws.ToString(in.LogStreamName)] {
+		return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("log stream %s does not exist", aws.ToString(in.LogStreamName)))}
+	}
+
+	reject := f.reject
+	f.reject = nil
+	first, last := rejectedRange(reject, len(in.LogEvents))
+	for _, e := range in.LogEvents[first:last] {
+		f.events = append(f.events, Event{
+			Group:     aws.ToString(in.LogGroupName),
+			Stream:    aws.ToString(in.LogStreamName),
+			Timestamp: time.UnixMilli(aws.ToInt64(e.Timestamp)),
+			Message:   aws.ToString(e.Message),
+		})
+	}
+	f.calls++
+	return &cloudwatchlogs.PutLogEventsOutput{RejectedLogEventsInfo: reject}, nil
+}
+
+// RejectNext makes the next PutLogEvents call accept only part of its batch,
+// as CloudWatch does with events that are too old or too new, and report the
+// rest as rejected by info. Rejected events are not recorded.
+func (f *FakeCloudwatch) RejectNext(info types.RejectedLogEventsInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reject = &info
+}
+
+// rejectedRange returns the range of the n events of a batch that info leaves
+// accepted.
+func rejectedRange(info *types.RejectedLogEventsInfo, n int) (first, last int) {
+	if info == nil {
+		return 0, n
+	}
+	last = n
+	for _, end := range []*int32{info.TooOldLogEventEndIndex, info.ExpiredLogEventEndIndex} {
+		if end != nil {
+			first = max(first, min(int(*end), n))
+		}
+	}
+	if info.TooNewLogEventStartIndex != nil {
+		last = max(min(int(*info.TooNewLogEventStartIndex), n), 0)
+	}
+	return first, max(first, last)
+}
+
+// CreateLogGroup creates the log group.
+func (f *FakeCloudwatch) CreateLogGroup(_ context.Context, in *cloudwatchlogs.CreateLogGroupInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := aws.ToString(in.LogGroupName)
+	if _, ok := f.groups[name]; ok {
+		return nil, &types.ResourceAlreadyExistsException{Message: aws.String(fmt.Sprintf("log group %s already exists", name))}
+	}
+	f.groups[name] = &logGroup{kmsKey: in.KmsKeyId, tags: in.Tags, streams: make(map[string]bool)}
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+// CreateLogStream creates the log stream.
+func (f *FakeCloudwatch) CreateLogStream(_ context.Context, in *cloudwatchlogs.CreateLogStreamInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	name := aws.ToString(in.LogStreamName)
+	if g.streams[name] {
+		return nil, &types.ResourceAlreadyExistsException{Message: aws.String(fmt.Sprintf("log stream %s already exists", name))}
+	}
+	g.streams[name] = true
+	return &cloudwatchlogs.CreateLogStreamOutput{}, nil
+}
+
+// DescribeLogGroups lists the log groups whose name contains the pattern or
+// starts with the prefix.
+func (f *FakeCloudwatch) DescribeLogGroups(_ context.Context, in *cloudwatchlogs.DescribeLogGroupsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for name, g := range f.groups {
+		if !strings.Contains(name, aws.ToString(in.LogGroupNamePattern)) || !strings.HasPrefix(name, aws.ToString(in.LogGroupNamePrefix)) {
+			continue
+		}
+		out.LogGroups = append(out.LogGroups, types.LogGroup{
+			LogGroupName:    aws.String(name),
+			RetentionInDays: g.retention,
+			KmsKeyId:        g.kmsKey,
+		})
+	}
+	return out, nil
+}
+
+// DescribeLogStreams lists the log streams of the group that start with the prefix.
+func (f *FakeCloudwatch) DescribeLogStreams(_ context.Context, in *cloudwatchlogs.DescribeLogStreamsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	out := &cloudwatchlogs.DescribeLogStreamsOutput{}
+	for _, name := range slices.Sorted(maps.Keys(g.streams)) {
+		if in.Limit != nil && len(out.LogStreams) >= int(*in.Limit) {
+			break
+		}
+		if strings.HasPrefix(name, aws.ToString(in.LogStreamNamePrefix)) {
+			out.LogStreams = append(out.LogStreams, types.LogStream{LogStreamName: aws.String(name)})
+		}
+	}
+	return out, nil
+}
+
+// PutRetentionPolicy sets the retention of the log group.
+func (f *FakeCloudwatch) PutRetentionPolicy(_ context.Context, in *cloudwatchlogs.PutRetentionPolicyInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	g.retention = in.RetentionInDays
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+// DeleteRetentionPolicy removes the retention of the log group.
+func (f *FakeCloudwatch) DeleteRetentionPolicy(_ context.Context, in *cloudwatchlogs.DeleteRetentionPolicyInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteRetentionPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	g.retention = nil
+	return &cloudwatchlogs.DeleteRetentionPolicyOutput{}, nil
+}
+
+// TagLogGroup adds the tags to the log group.
+func (f *FakeCloudwatch) TagLogGroup(_ context.Context, in *cloudwatchlogs.TagLogGroupInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.TagLogGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	if g.tags == nil {
+		g.tags = make(map[string]string, len(in.Tags))
+	}
+	for k, v := range in.Tags {
+		g.tags[k] = v
+	}
+	return &cloudwatchlogs.TagLogGroupOutput{}, nil
+}
+
+// AssociateKmsKey sets the KMS key of the log group.
+func (f *FakeCloudwatch) AssociateKmsKey(_ context.Context, in *cloudwatchlogs.AssociateKmsKeyInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.AssociateKmsKeyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, err := f.group(in.LogGroupName)
+	if err != nil {
+		return nil, err
+	}
+	g.kmsKey = in.KmsKeyId
+	return &cloudwatchlogs.AssociateKmsKeyOutput{}, nil
+}
+
+var _ slogcloud.CloudwatchAPI = (*FakeCloudwatch)(nil)
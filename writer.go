@@ -0,0 +1,161 @@
+package slogcloud
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxWriterLine is the number of bytes a Writer buffers without seeing a
+// newline before it logs them as a line of their own.
+const maxWriterLine = 64 * 1024
+
+// Writer returns an io.Writer that logs every line written to it as an info
+// record whose message is the line without surrounding whitespace, so the
+// output of libraries that only accept an io.Writer or a *log.Logger can be
+// sent to CloudWatch:
+//
+//	log.SetOutput(cwClient.Writer())
+//
+// Lines may be split across several writes; an incomplete line is held until
+// its newline is written. Blank lines are skipped. The returned writer is safe
+// for concurrent use.
+func (cw *CloudwatchClient) Writer() io.Writer {
+	return &lineWriter{cw: cw}
+}
+
+// RedirectStdLog sends everything written through the standard library's
+// global logger, such as log.Printf calls in dependencies, to cw. Lines that
+// start with a level such as "[ERROR]" or "warn:" are logged at that level
+// with the prefix removed; all others are logged at info. It clears the log
+// flags, as CloudWatch timestamps each event, and returns a function that
+// restores the previous output, flags and prefix.
+//
+// Clients given WithDebugf(log.Printf) keep writing their diagnostics to the
+// previous output meanwhile, so they are not sent back into a client, which
+// could deadlock once its queue is full under OverflowBlock.
+func RedirectStdLog(cw *CloudwatchClient) func() {
+	out, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+	saved := stdLogOutput.Load()
+	if saved == nil {
+		stdLogOutput.Store(log.New(out, prefix, flags))
+	}
+
+	log.SetOutput(&lineWriter{cw: cw, parseLevel: true})
+	log.SetFlags(0)
+	log.SetPrefix("")
+
+	return func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		stdLogOutput.Store(saved)
+	}
+}
+
+// stdLogOutput is the global logger's output from before RedirectStdLog, as
+// a logger of its own, while the redirect is in effect.
+var stdLogOutput atomic.Pointer[log.Logger]
+
+// stdLogDebugf stands in for log.Printf given to WithDebugf. It writes to
+// stdLogOutput while the global logger is redirected to a client.
+func stdLogDebugf(format string, args ...any) {
+	if l := stdLogOutput.Load(); l != nil {
+		l.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// isStdLogPrintf reports whether debugf is log.Printf.
+func isStdLogPrintf(debugf func(format string, args ...any)) bool {
+	return reflect.ValueOf(debugf).Pointer() == reflect.ValueOf(log.Printf).Pointer()
+}
+
+// lineWriter is the io.Writer returned by CloudwatchClient.Writer.
+type lineWriter struct {
+	cw *CloudwatchClient
+	// parseLevel takes the level of each line from its prefix
+	parseLevel bool
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write logs every complete line in p and buffers the rest. It reports the
+// first error returned by the client, after every line has been logged.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var firstErr error
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) < maxWriterLine {
+				break
+			}
+			i = len(w.buf)
+		}
+
+		if err := w.emit(w.buf[:i]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if i == len(w.buf) {
+			w.buf = w.buf[:0]
+		} else {
+			w.buf = w.buf[i+1:]
+		}
+	}
+
+	// Release the memory of a long line once it has been logged
+	if len(w.buf) == 0 && cap(w.buf) > maxWriterLine {
+		w.buf = nil
+	}
+
+	return len(p), firstErr
+}
+
+// emit logs line, unless it is blank.
+func (w *lineWriter) emit(line []byte) error {
+	msg := string(bytes.TrimSpace(line))
+	if msg == "" {
+		return nil
+	}
+
+	level := slog.LevelInfo
+	if w.parseLevel {
+		level, msg = splitLevelPrefix(msg)
+	}
+	return w.cw.EmitLog(slog.NewRecord(w.cw.opts.clock.Now(), level, msg, 0))
+}
+
+// splitLevelPrefix removes a leading level written as "[LEVEL]" or "LEVEL:"
+// from msg and returns it. Lines without one are at info.
+func splitLevelPrefix(msg string) (slog.Level, string) {
+	var name, rest string
+	var ok bool
+	if strings.HasPrefix(msg, "[") {
+		name, rest, ok = strings.Cut(msg[1:], "]")
+	} else {
+		name, rest, ok = strings.Cut(msg, ":")
+	}
+	if !ok || strings.ContainsAny(name, " \t") {
+		return slog.LevelInfo, msg
+	}
+
+	level, err := ParseLevel(name)
+	if err != nil {
+		return slog.LevelInfo, msg
+	}
+	if rest = strings.TrimSpace(rest); rest == "" {
+		return slog.LevelInfo, msg
+	}
+	return level, rest
+}
@@ -0,0 +1,46 @@
+package slogcloud
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestRedirectStdLogKeepsDebugfOut(t *testing.T) {
+	var out bytes.Buffer
+	prevOut, prevFlags := log.Writer(), log.Flags()
+	log.SetOutput(&out)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	}()
+
+	cw, fake := newTestClient(t, WithDebugf(log.Printf))
+	restore := RedirectStdLog(cw)
+	defer restore()
+
+	log.Printf("[WARN] disk almost full")
+	cw.opts.debugf("diagnostic %d", 1)
+	if err := cw.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	messages := fake.messages()
+	if len(messages) != 1 || !strings.Contains(messages[0], `"disk almost full"`) {
+		t.Errorf("sent %q, want only the application's log", messages)
+	}
+	if got := out.String(); !strings.Contains(got, "diagnostic 1") || strings.Contains(got, "disk almost full") {
+		t.Errorf("previous log output got %q, want only the diagnostic", got)
+	}
+
+	// Once restored, log.Printf is used as usual
+	restore()
+	out.Reset()
+	cw.opts.debugf("diagnostic %d", 2)
+	if got := out.String(); got != "diagnostic 2\n" {
+		t.Errorf("log output got %q, want the diagnostic", got)
+	}
+}